@@ -0,0 +1,322 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BackupDir is the name of the directory holding the backups of the state
+// files of a container, in its libcontainerd directory.
+const BackupDir = ".upgrade-backup"
+
+// manifestFile is the name of the manifest of a backup. A backup without a
+// manifest is incomplete.
+const manifestFile = "manifest.json"
+
+// generationFormat names the backups so that they sort in the order they were
+// taken.
+const generationFormat = "20060102T150405.000000000Z"
+
+// ErrNoBackup is returned by Rollback when there is no backup to restore.
+var ErrNoBackup = errors.New("no backup found")
+
+// Backups returns the path of the directory holding the backups of c.
+func (c Container) Backups() string {
+	return filepath.Join(filepath.Dir(c.Config), BackupDir)
+}
+
+// Backup is a copy of the state files of a container, taken before upgrading
+// them.
+type Backup struct {
+	// Generation is the name of the directory of the backup.
+	Generation string       `json:"-"`
+	Created    time.Time    `json:"created"`
+	Files      []BackupFile `json:"files"`
+	// Skipped holds the state files of the exec processes that Rollback
+	// did not restore, because the processes have exited since.
+	Skipped []string `json:"-"`
+}
+
+// BackupFile is a state file saved in a backup.
+type BackupFile struct {
+	// Path is the path of the state file.
+	Path string      `json:"path"`
+	Mode os.FileMode `json:"mode"`
+	// Digest is the sha256 of the contents of the file.
+	Digest string `json:"digest"`
+}
+
+// name is the name of the copy of f in the backup directory.
+func (f BackupFile) name() string {
+	return filepath.Base(f.Path)
+}
+
+// Retention limits the number of backups kept for a container. The most
+// recent backup is always kept.
+type Retention struct {
+	// Keep is the number of backups to keep, all of them if zero.
+	Keep int
+	// MaxAge is the age after which backups are removed. Backups are
+	// never too old if zero.
+	MaxAge time.Duration
+}
+
+// CreateBackup copies the state files of c, those of its exec processes
+// included, to a new generation of its backups.
+func CreateBackup(c Container) (*Backup, error) {
+	c, err := c.withExecs()
+	if err != nil {
+		return nil, err
+	}
+	fs := c.fs()
+	now := time.Now().UTC()
+	b := &Backup{Generation: now.Format(generationFormat), Created: now}
+	dir := filepath.Join(c.Backups(), b.Generation)
+	if _, err := fs.Stat(dir); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", dir)
+	}
+	if err := fs.MkdirAll(dir, 0700); err != nil {
+		return nil, &WriteError{File: dir, Op: "backup", Err: err}
+	}
+	if err := writeBackup(fs, dir, b, c.Files()); err != nil {
+		fs.RemoveAll(dir)
+		return nil, err
+	}
+	return b, nil
+}
+
+func writeBackup(fs FS, dir string, b *Backup, files []string) error {
+	for _, path := range files {
+		fi, err := fs.Stat(path)
+		if err != nil {
+			return err
+		}
+		data, err := readFile(fs, path)
+		if err != nil {
+			return err
+		}
+		f := BackupFile{Path: path, Mode: fi.Mode(), Digest: digest(data)}
+		if err := fs.WriteFile(filepath.Join(dir, f.name()), data, fi.Mode()); err != nil {
+			return &WriteError{File: filepath.Join(dir, f.name()), Op: "backup", Err: err}
+		}
+		b.Files = append(b.Files, f)
+	}
+	manifest, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	// the manifest is written last, as it marks the backup complete.
+	if err := fs.WriteFile(filepath.Join(dir, manifestFile), manifest, 0600); err != nil {
+		return &WriteError{File: filepath.Join(dir, manifestFile), Op: "backup", Err: err}
+	}
+	if err := fs.SyncDir(dir); err != nil {
+		return &WriteError{File: dir, Op: "backup", Err: err}
+	}
+	if err := fs.SyncDir(filepath.Dir(dir)); err != nil {
+		return &WriteError{File: dir, Op: "backup", Err: err}
+	}
+	return nil
+}
+
+// ListBackups returns the complete backups of c, oldest first.
+func ListBackups(c Container) ([]*Backup, error) {
+	fs := c.fs()
+	entries, err := fs.ReadDir(c.Backups())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var backups []*Backup
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		b, err := readManifest(fs, filepath.Join(c.Backups(), e.Name()))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		backups = append(backups, b)
+	}
+	return backups, nil
+}
+
+func readManifest(fs FS, dir string) (*Backup, error) {
+	data, err := readFile(fs, filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	b := &Backup{Generation: filepath.Base(dir)}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("error decoding the manifest of %s: %v", dir, err)
+	}
+	return b, nil
+}
+
+// PruneBackups removes the backups of c that are not retained by r, as well
+// as the incomplete ones, and returns the generations removed. c should be
+// locked, or a backup being written could be removed.
+func PruneBackups(c Container, r Retention) ([]string, error) {
+	fs := c.fs()
+	backups, err := ListBackups(c)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool)
+	for i, b := range backups {
+		newest := i == len(backups)-1
+		tooMany := r.Keep > 0 && i < len(backups)-r.Keep
+		tooOld := r.MaxAge > 0 && time.Since(b.Created) > r.MaxAge
+		keep[b.Generation] = newest || !tooMany && !tooOld
+	}
+	entries, err := fs.ReadDir(c.Backups())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var removed []string
+	for _, e := range entries {
+		if keep[e.Name()] {
+			continue
+		}
+		if err := fs.RemoveAll(filepath.Join(c.Backups(), e.Name())); err != nil {
+			return removed, err
+		}
+		removed = append(removed, e.Name())
+	}
+	return removed, nil
+}
+
+// Rollback restores the state files of c saved in the backup generation, or
+// in the most recent backup if generation is empty. The copies are checked
+// against the manifest before any file is replaced, and the files are
+// replaced all together or not at all. Rollback waits up to lockTimeout for
+// the state directories of c; see Lock. The exec processes that have exited
+// since the backup are not restored, and are listed in Backup.Skipped; the
+// other files are restored even if they were removed.
+func Rollback(c Container, generation string, lockTimeout time.Duration) (*Backup, error) {
+	unlock, err := Lock(c, lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := Recover(c); err != nil {
+		return nil, fmt.Errorf("error recovering interrupted upgrade of %s: %w", c.ID, err)
+	}
+	fs := c.fs()
+	var b *Backup
+	if generation == "" {
+		backups, err := ListBackups(c)
+		if err != nil {
+			return nil, err
+		}
+		if len(backups) == 0 {
+			return nil, ErrNoBackup
+		}
+		b = backups[len(backups)-1]
+	} else {
+		if generation != filepath.Base(generation) {
+			return nil, fmt.Errorf("invalid backup generation: %s", generation)
+		}
+		if b, err = readManifest(fs, filepath.Join(c.Backups(), generation)); err != nil {
+			if os.IsNotExist(err) {
+				return nil, ErrNoBackup
+			}
+			return nil, err
+		}
+	}
+	dir := filepath.Join(c.Backups(), b.Generation)
+	var docs, missing []*Document
+	modes := make(map[string]os.FileMode)
+	for _, f := range b.Files {
+		if !c.owns(f.Path) {
+			return nil, &ConsistencyError{Container: c.ID, Files: []string{f.Path}, Reason: fmt.Sprintf("backup %s holds a file that is not a state file of the container", b.Generation)}
+		}
+		if c.isExec(f.Path) {
+			// libcontainerd removes the state of the exec processes
+			// that exit: bringing it back would have it restore a
+			// process that is gone.
+			if _, err := fs.Stat(f.Path); os.IsNotExist(err) {
+				b.Skipped = append(b.Skipped, f.Path)
+				continue
+			}
+		}
+		data, err := readFile(fs, filepath.Join(dir, f.name()))
+		if err != nil {
+			return nil, err
+		}
+		if digest(data) != f.Digest {
+			return nil, &ConsistencyError{Container: c.ID, Files: []string{f.Path}, Reason: fmt.Sprintf("the copy in backup %s does not match its digest", b.Generation)}
+		}
+		if _, err := fs.Stat(f.Path); os.IsNotExist(err) {
+			missing = append(missing, &Document{Path: f.Path, Data: data})
+			modes[f.Path] = f.Mode
+			continue
+		}
+		docs = append(docs, &Document{Path: f.Path, Data: data})
+	}
+	restored, err := restoreMissing(fs, missing, modes)
+	if err != nil {
+		removeRestored(fs, restored)
+		return nil, err
+	}
+	if err := commit(fs, c.Journal(), docs); err != nil {
+		removeRestored(fs, restored)
+		return nil, err
+	}
+	if err := fs.Remove(c.Marker()); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return b, nil
+}
+
+// restoreMissing writes back the given files of a backup, with the given
+// modes, where they were removed since, as by DeadCleanup, and returns the
+// paths written. Each file is written next to its path then renamed, so that
+// it is either complete or absent.
+func restoreMissing(fs FS, docs []*Document, modes map[string]os.FileMode) ([]string, error) {
+	var restored []string
+	for _, doc := range docs {
+		if err := fs.WriteFile(doc.Path+stagedSuffix, doc.Data, modes[doc.Path]); err != nil {
+			return restored, &WriteError{File: doc.Path, Op: "rollback", Err: err}
+		}
+		if err := fs.Rename(doc.Path+stagedSuffix, doc.Path); err != nil {
+			fs.Remove(doc.Path + stagedSuffix)
+			return restored, &WriteError{File: doc.Path, Op: "rollback", Err: err}
+		}
+		restored = append(restored, doc.Path)
+		if err := fs.SyncDir(filepath.Dir(doc.Path)); err != nil {
+			return restored, &WriteError{File: doc.Path, Op: "rollback", Err: err}
+		}
+	}
+	return restored, nil
+}
+
+// removeRestored undoes restoreMissing, so that the files of a backup are
+// restored all together or not at all.
+func removeRestored(fs FS, paths []string) {
+	for _, path := range paths {
+		fs.Remove(path)
+	}
+}
+
+// owns reports whether path is one of the state files of c, including the
+// exec processes that may have exited since.
+func (c Container) owns(path string) bool {
+	for _, name := range c.Files() {
+		if name == path {
+			return true
+		}
+	}
+	return c.isExec(path)
+}
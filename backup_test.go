@@ -0,0 +1,106 @@
+package upgrade
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// backups creates n backups of c, writing "<i> <kind>" to its state files
+// before each of them, and returns their generations.
+func backups(t *testing.T, c Container, n int) []string {
+	var generations []string
+	for i := 0; i < n; i++ {
+		for _, doc := range c.documents() {
+			if err := c.fs().WriteFile(doc.Path, []byte(fmt.Sprintf("%d %s", i, doc.Kind)), 0640); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b, err := CreateBackup(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		generations = append(generations, b.Generation)
+		// generations are named after the time they are taken.
+		time.Sleep(time.Millisecond)
+	}
+	return generations
+}
+
+func TestBackups(t *testing.T) {
+	fs := NewMemFS()
+	c, _ := newJournalContainer(t, fs, "/")
+	generations := backups(t, c, 3)
+	// an incomplete backup is ignored, then pruned.
+	if err := fs.MkdirAll(filepath.Join(c.Backups(), "incomplete"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	list, err := ListBackups(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	for _, b := range list {
+		listed = append(listed, b.Generation)
+	}
+	if !reflect.DeepEqual(listed, generations) {
+		t.Fatalf("%v | %v", generations, listed)
+	}
+
+	for _, d := range [...]struct {
+		name      string
+		retention Retention
+		removed   []string
+	}{
+		{"unlimited", Retention{}, []string{"incomplete"}},
+		{"keep 2", Retention{Keep: 2}, generations[:1]},
+		{"too old", Retention{MaxAge: time.Nanosecond}, generations[1:2]},
+	} {
+		removed, err := PruneBackups(c, d.retention)
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		if !reflect.DeepEqual(removed, d.removed) {
+			t.Fatalf("%s: %v | %v", d.name, d.removed, removed)
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	fs := NewMemFS()
+	c, _ := newJournalContainer(t, fs, "/")
+	generations := backups(t, c, 2)
+	b, err := Rollback(c, generations[0], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Generation != generations[0] {
+		t.Fatalf("restored %s", b.Generation)
+	}
+	checkFiles(t, c, "0 ")
+	if _, err = Rollback(c, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, c, "1 ")
+
+	// a corrupted copy prevents restoring any file.
+	if err := fs.WriteFile(filepath.Join(c.Backups(), generations[0], ConfigFile), []byte("0 garbage"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Rollback(c, generations[0], 0); err == nil {
+		t.Fatal("expected an error")
+	}
+	checkFiles(t, c, "1 ")
+
+	for _, generation := range []string{"missing", "../" + generations[0]} {
+		if _, err := Rollback(c, generation, 0); err == nil {
+			t.Fatalf("%s: expected an error", generation)
+		}
+	}
+	c, _ = newJournalContainer(t, NewMemFS(), "/")
+	if _, err := Rollback(c, "", 0); err != ErrNoBackup {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
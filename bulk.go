@@ -0,0 +1,130 @@
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BulkOptions configures UpgradeBulk.
+type BulkOptions struct {
+	Options
+	// Workers is the number of containers upgraded concurrently, 1 if not
+	// positive.
+	Workers int
+	// Timeout is the time allowed for the whole run, unlimited if zero.
+	Timeout time.Duration
+	// Upgrade upgrades a single container, Upgrade if nil. It may return
+	// a nil report if there was nothing to do.
+	Upgrade func(Container, Options) (*Report, error)
+}
+
+// BulkResult is the outcome of upgrading a single container with
+// UpgradeBulk.
+type BulkResult struct {
+	ID     string
+	Report *Report
+	Err    error
+}
+
+// Skipped reports whether the container was left alone because its init
+// process is not running.
+func (r BulkResult) Skipped() bool {
+	return r.Err == nil && r.Report != nil && r.Report.Liveness != LivenessUnknown && r.Report.Liveness != LivenessLive
+}
+
+// BulkReport is the result of UpgradeBulk. The IDs are listed in the order of
+// the inventory.
+type BulkReport struct {
+	// Results has one entry per container of the inventory, in order.
+	Results   []BulkResult
+	Succeeded []string
+	Skipped   []string
+	Failed    []string
+}
+
+// UpgradeBulk upgrades the containers of inv with at most opts.Workers of
+// them in flight. Each container is upgraded on its own: a failure, even a
+// panic, only fails that container.
+//
+// When ctx is done or opts.Timeout expires, the upgrades in progress are
+// completed, and the containers not started yet fail with the error of the
+// context.
+func UpgradeBulk(ctx context.Context, inv *Inventory, opts BulkOptions) *BulkReport {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	upgrade := opts.Upgrade
+	if upgrade == nil {
+		upgrade = Upgrade
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]BulkResult, len(inv.Containers))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				c := inv.Containers[i]
+				results[i] = BulkResult{ID: c.ID}
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Report, results[i].Err = upgradeIsolated(upgrade, c, opts.Options)
+			}
+		}()
+	}
+	for i := range inv.Containers {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	report := &BulkReport{Results: results}
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			report.Failed = append(report.Failed, r.ID)
+		case r.Skipped():
+			report.Skipped = append(report.Skipped, r.ID)
+		default:
+			report.Succeeded = append(report.Succeeded, r.ID)
+		}
+	}
+	return report
+}
+
+// Err returns the failures of the run as Errors of *ContainerError, in the
+// order of the inventory, or nil if no container failed.
+func (r *BulkReport) Err() error {
+	var errs Errors
+	for _, res := range r.Results {
+		if res.Err != nil {
+			errs = append(errs, &ContainerError{ID: res.ID, Err: res.Err})
+		}
+	}
+	if errs == nil {
+		return nil
+	}
+	return errs
+}
+
+// upgradeIsolated calls upgrade, turning a panic into an error.
+func upgradeIsolated(upgrade func(Container, Options) (*Report, error), c Container, opts Options) (report *Report, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			report, err = nil, fmt.Errorf("panic upgrading %s: %v", c.ID, r)
+		}
+	}()
+	return upgrade(c, opts)
+}
@@ -0,0 +1,90 @@
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func bulkInventory(ids ...string) *Inventory {
+	inv := &Inventory{}
+	for _, id := range ids {
+		inv.Containers = append(inv.Containers, NewContainer("/runc", "/libcontainerd", id))
+	}
+	return inv
+}
+
+func TestUpgradeBulk(t *testing.T) {
+	var (
+		mu              sync.Mutex
+		inFlight, maxIn int
+	)
+	inv := bulkInventory("a", "dead", "fail", "panic", "b", "c")
+	report := UpgradeBulk(context.Background(), inv, BulkOptions{
+		Workers: 2,
+		Upgrade: func(c Container, opts Options) (*Report, error) {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxIn {
+				maxIn = inFlight
+			}
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+			}()
+			time.Sleep(10 * time.Millisecond)
+			switch c.ID {
+			case "dead":
+				return &Report{Liveness: LivenessDead}, nil
+			case "fail":
+				return nil, errors.New("failed")
+			case "panic":
+				panic("boom")
+			}
+			return &Report{Liveness: LivenessLive}, nil
+		},
+	})
+	if maxIn != 2 {
+		t.Fatalf("%d upgrades in flight, expected 2", maxIn)
+	}
+	for _, d := range [...]struct {
+		name     string
+		ids      []string
+		expected []string
+	}{
+		{"succeeded", report.Succeeded, []string{"a", "b", "c"}},
+		{"skipped", report.Skipped, []string{"dead"}},
+		{"failed", report.Failed, []string{"fail", "panic"}},
+	} {
+		if !reflect.DeepEqual(d.ids, d.expected) {
+			t.Fatalf("%s: %v | %v", d.name, d.expected, d.ids)
+		}
+	}
+	for i, r := range report.Results {
+		if r.ID != inv.Containers[i].ID {
+			t.Fatalf("result %d is for %s, expected %s", i, r.ID, inv.Containers[i].ID)
+		}
+	}
+}
+
+func TestUpgradeBulkTimeout(t *testing.T) {
+	report := UpgradeBulk(context.Background(), bulkInventory("a", "b", "c"), BulkOptions{
+		Workers: 1,
+		Timeout: 20 * time.Millisecond,
+		Upgrade: func(c Container, opts Options) (*Report, error) {
+			time.Sleep(50 * time.Millisecond)
+			return nil, nil
+		},
+	})
+	if !reflect.DeepEqual(report.Succeeded, []string{"a"}) || !reflect.DeepEqual(report.Failed, []string{"b", "c"}) {
+		t.Fatalf("succeeded %v, failed %v", report.Succeeded, report.Failed)
+	}
+	if err := report.Results[1].Err; err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
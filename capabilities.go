@@ -0,0 +1,81 @@
+package upgrade
+
+import "fmt"
+
+// CapabilitySets are the capabilities of a process, split into the sets of
+// the Linux capability model as of Docker 17.06.
+type CapabilitySets struct {
+	Bounding    []string
+	Effective   []string
+	Inheritable []string
+	Permitted   []string
+	Ambient     []string
+}
+
+// A CapabilityPolicy expands the flat list of capabilities of a process,
+// written by Docker before 17.06, into capability sets. uid is the user the
+// process runs as, or nil if the document does not record it, as the runc
+// state does. The same policy is applied to the config and to the state files
+// of a container: Upgrade expands the capabilities of the runc state for the
+// user of the config.
+type CapabilityPolicy func(caps []string, uid *uint32) CapabilitySets
+
+// forUser returns p, applied to uid for the documents that do not record the
+// user of the process.
+func (p CapabilityPolicy) forUser(uid *uint32) CapabilityPolicy {
+	if p == nil || uid == nil {
+		return p
+	}
+	return func(caps []string, u *uint32) CapabilitySets {
+		if u == nil {
+			u = uid
+		}
+		return p(caps, u)
+	}
+}
+
+// DockerCapabilities is the default CapabilityPolicy. It gives every
+// capability in the bounding, effective, inheritable and permitted sets and
+// none in the ambient set, as Docker 17.06 does for all users.
+func DockerCapabilities(caps []string, uid *uint32) CapabilitySets {
+	return CapabilitySets{
+		Bounding:    caps,
+		Effective:   caps,
+		Inheritable: caps,
+		Permitted:   caps,
+	}
+}
+
+// NoInheritableCapabilities is like DockerCapabilities, but leaves the
+// inheritable set empty, as Docker does since 20.10.14: the capabilities are
+// not passed on to the programs executed with file capabilities.
+func NoInheritableCapabilities(caps []string, uid *uint32) CapabilitySets {
+	sets := DockerCapabilities(caps, uid)
+	sets.Inheritable = nil
+	return sets
+}
+
+// NonRootAmbientCapabilities is like DockerCapabilities, but also gives every
+// capability in the ambient set to the processes known to run as another user
+// than root, so that they keep them across execve.
+func NonRootAmbientCapabilities(caps []string, uid *uint32) CapabilitySets {
+	sets := DockerCapabilities(caps, uid)
+	if uid != nil && *uid != 0 {
+		sets.Ambient = caps
+	}
+	return sets
+}
+
+// ParseCapabilityPolicy returns the policy named "docker", "no-inheritable" or
+// "non-root-ambient".
+func ParseCapabilityPolicy(s string) (CapabilityPolicy, error) {
+	switch s {
+	case "docker":
+		return DockerCapabilities, nil
+	case "no-inheritable":
+		return NoInheritableCapabilities, nil
+	case "non-root-ambient":
+		return NonRootAmbientCapabilities, nil
+	}
+	return nil, fmt.Errorf("unknown capability policy: %s", s)
+}
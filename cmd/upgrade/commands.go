@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+func upgradeCommand(a *app, args []string) int {
+	ids, ok := a.parse("upgrade", args)
+	if !ok {
+		return exitUsage
+	}
+	containers, orphans, err := a.containers(ids)
+	if err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	inv := &upgrade.Inventory{Containers: containers}
+	bulk := upgrade.UpgradeBulk(context.Background(), inv, upgrade.BulkOptions{
+		Options: a.options(),
+		Workers: a.workers,
+		Timeout: a.timeout,
+	})
+	var results []result
+	// Upgrade decides under the lock of each container whether it needs
+	// an upgrade with the given options, and leaves its files unchanged if
+	// not.
+	for _, r := range bulk.Results {
+		status, detail := statusUnchanged, ""
+		if report := r.Report; report != nil {
+			switch {
+			case report.Removed:
+				status, detail = statusRemoved, "init process is "+report.Liveness.String()
+			case report.Liveness != upgrade.LivenessLive && report.Liveness != upgrade.LivenessUnknown:
+				status, detail = statusSkipped, "init process is "+report.Liveness.String()
+			case len(report.Files) == 0:
+			case a.dryRun:
+				status = statusNeedsUpgrade
+			default:
+				status = statusUpgraded
+			}
+			if detail == "" && len(report.Writers) > 0 {
+				detail = "warning: running " + joinWriters(report.Writers)
+			}
+		}
+		res := newResult(r.ID, status, r.Err)
+		res.Detail = detail
+		if r.Report != nil {
+			res.Backup = r.Report.Backup
+			for _, p := range r.Report.Processes {
+				res.Processes = append(res.Processes, process{ID: p.ID, Path: p.Path, From: p.From, Changed: p.Changed})
+			}
+		}
+		// a report returned with an error holds the invalid files. A
+		// report that cannot be encoded only fails its own container.
+		changes, err := files(r.Report)
+		if err != nil {
+			res.Status = statusFailed
+			if res.Error == "" {
+				res.Error = err.Error()
+			}
+		}
+		res.Files = changes
+		results = append(results, res)
+	}
+	results = append(results, orphanResults(orphans)...)
+	if err := a.printResults(results); err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	if a.dryRun {
+		return exitCode(results, statusNeedsUpgrade)
+	}
+	return exitCode(results, statusUpgraded)
+}
+
+func (a *app) options() upgrade.Options {
+	return upgrade.Options{
+		Target:          a.target,
+		DryRun:          a.dryRun,
+		PreserveUnknown: a.preserve,
+		Normalize:       a.normalize,
+		Capabilities:    a.capabilities,
+		Strict:          a.strict,
+		ProcRoot:        a.procRoot,
+		IgnoreLiveness:  a.ignoreLiveness,
+		Dead:            a.dead,
+		LockTimeout:     a.lockTimeout,
+		RefuseWriters:   a.refuseWriters,
+		Retention:       a.retention,
+		Marker:          a.marker,
+		SkipValidation:  a.skipValidation,
+		AllowInvalid:    a.allowInvalid,
+	}
+}
+
+func joinWriters(writers []upgrade.Writer) string {
+	s := make([]string, len(writers))
+	for i, w := range writers {
+		s[i] = w.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// files returns the changes of report as JSON patches.
+func files(report *upgrade.Report) ([]fileChange, error) {
+	if report == nil {
+		return nil, nil
+	}
+	var files []fileChange
+	for _, f := range report.Files {
+		patch, err := f.Diff.JSONPatch()
+		if err != nil {
+			return nil, err
+		}
+		var unknown, losses, violations []string
+		for _, field := range f.Unknown {
+			unknown = append(unknown, field.String())
+		}
+		for _, loss := range f.Losses {
+			losses = append(losses, loss.String())
+		}
+		for _, v := range f.Violations {
+			violations = append(violations, v.String())
+		}
+		files = append(files, fileChange{
+			Path:       f.Path,
+			From:       f.From,
+			To:         f.To,
+			Patch:      patch,
+			Unknown:    unknown,
+			Losses:     losses,
+			Violations: violations,
+			diff:       f.Diff,
+		})
+	}
+	return files, nil
+}
+
+// versions returns the version of each document, keyed by file name.
+func versions(docs []*upgrade.Document) map[string]upgrade.Version {
+	versions := make(map[string]upgrade.Version)
+	for _, doc := range docs {
+		versions[filepath.Base(doc.Path)] = doc.Version
+	}
+	return versions
+}
+
+func checkCommand(a *app, args []string) int {
+	ids, ok := a.parse("check", args)
+	if !ok {
+		return exitUsage
+	}
+	containers, orphans, err := a.containers(ids)
+	if err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	var results []result
+	for _, c := range containers {
+		docs, err := upgrade.Load(c)
+		if err != nil {
+			results = append(results, newResult(c.ID, statusFailed, err))
+			continue
+		}
+		needed, err := upgrade.NeedsUpgrade(c, a.target)
+		if err != nil {
+			results = append(results, newResult(c.ID, statusFailed, err))
+			continue
+		}
+		status := statusUnchanged
+		if needed {
+			status = statusNeedsUpgrade
+		}
+		r := newResult(c.ID, status, nil)
+		r.Versions = versions(docs)
+		if a.strict {
+			// decode the files to find the fields unknown to the upgrade.
+			for _, doc := range docs {
+				if _, err := upgrade.Convert(doc, a.options()); err != nil {
+					r = newResult(c.ID, statusFailed, err)
+					break
+				}
+			}
+		}
+		results = append(results, r)
+	}
+	results = append(results, orphanResults(orphans)...)
+	if err := a.printResults(results); err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	return exitCode(results, statusNeedsUpgrade)
+}
+
+type inspection struct {
+	ID       string                     `json:"id"`
+	Versions map[string]upgrade.Version `json:"versions,omitempty"`
+	State    json.RawMessage            `json:"state,omitempty"`
+	Config   json.RawMessage            `json:"config,omitempty"`
+	Process  json.RawMessage            `json:"process,omitempty"`
+	// Execs holds the states of the exec processes, keyed by exec ID.
+	Execs map[string]json.RawMessage `json:"execs,omitempty"`
+	// Unknown lists the fields not understood by the upgrade, keyed by
+	// file name.
+	Unknown map[string][]string `json:"unknown,omitempty"`
+	// Losses lists the information that could not be converted, keyed by
+	// file name.
+	Losses map[string][]string `json:"losses,omitempty"`
+	Error  string              `json:"error,omitempty"`
+}
+
+func inspectCommand(a *app, args []string) int {
+	ids, ok := a.parse("inspect", args)
+	if !ok {
+		return exitUsage
+	}
+	containers, _, err := a.containers(ids)
+	if err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	code := exitOK
+	var inspections []inspection
+	for _, c := range containers {
+		i, err := a.inspectContainer(c)
+		if err != nil {
+			i.Error = err.Error()
+			code = exitFailed
+		}
+		inspections = append(inspections, i)
+	}
+	if err := a.printJSON(inspections); err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	return code
+}
+
+// inspectContainer converts the state files of c in memory.
+func (a *app) inspectContainer(c upgrade.Container) (inspection, error) {
+	i := inspection{ID: c.ID}
+	docs, err := upgrade.Load(c)
+	if err != nil {
+		return i, err
+	}
+	i.Versions = versions(docs)
+	for _, doc := range docs {
+		file, err := upgrade.Convert(doc, a.options())
+		if err != nil {
+			return i, err
+		}
+		name := filepath.Base(doc.Path)
+		for _, field := range file.Unknown {
+			if i.Unknown == nil {
+				i.Unknown = make(map[string][]string)
+			}
+			i.Unknown[name] = append(i.Unknown[name], field.String())
+		}
+		for _, loss := range file.Losses {
+			if i.Losses == nil {
+				i.Losses = make(map[string][]string)
+			}
+			i.Losses[name] = append(i.Losses[name], loss.String())
+		}
+		switch doc.Kind {
+		case upgrade.KindState:
+			i.State = doc.Data
+		case upgrade.KindConfig:
+			i.Config = doc.Data
+		case upgrade.KindProcess:
+			if doc.Path == c.Process {
+				i.Process = doc.Data
+				break
+			}
+			if i.Execs == nil {
+				i.Execs = make(map[string]json.RawMessage)
+			}
+			i.Execs[upgrade.ProcessID(doc.Path)] = doc.Data
+		}
+	}
+	return i, nil
+}
+
+func rollbackCommand(a *app, args []string) int {
+	ids, ok := a.parse("rollback", args)
+	if !ok {
+		return exitUsage
+	}
+	containers, _, err := a.containers(ids)
+	if err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	var results []result
+	for _, c := range containers {
+		status := statusRestored
+		b, err := upgrade.Rollback(c, a.generation, a.lockTimeout)
+		if err == upgrade.ErrNoBackup {
+			status, err = statusUnchanged, nil
+		}
+		res := newResult(c.ID, status, err)
+		if b != nil {
+			res.Backup = b.Generation
+			if len(b.Skipped) > 0 {
+				var ids []string
+				for _, path := range b.Skipped {
+					ids = append(ids, upgrade.ProcessID(path))
+				}
+				res.Detail = "exited exec processes not restored: " + strings.Join(ids, ", ")
+			}
+		}
+		results = append(results, res)
+	}
+	if err := a.printResults(results); err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	return exitCode(results, statusRestored)
+}
+
+func backupsCommand(a *app, args []string) int {
+	ids, ok := a.parse("backups", args)
+	if !ok {
+		return exitUsage
+	}
+	containers, _, err := a.containers(ids)
+	if err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	var results []result
+	for _, c := range containers {
+		list, err := upgrade.ListBackups(c)
+		status := statusBackedUp
+		if len(list) == 0 {
+			status = statusNoBackup
+		}
+		res := newResult(c.ID, status, err)
+		var generations []string
+		for _, b := range list {
+			res.Backups = append(res.Backups, backup{Generation: b.Generation, Created: b.Created})
+			generations = append(generations, b.Generation)
+		}
+		res.Detail = strings.Join(generations, " ")
+		results = append(results, res)
+	}
+	if err := a.printResults(results); err != nil {
+		fmt.Fprintln(a.stderr, err)
+		return exitFailed
+	}
+	return exitCode(results, statusBackedUp)
+}
@@ -0,0 +1,386 @@
+// Command upgrade converts the on-disk state of live-restored containers to
+// the format expected by a newer Docker engine.
+//
+// Exit codes:
+//
+//	0  files were upgraded (check: an upgrade is needed)
+//	1  an error occurred
+//	2  invalid usage
+//	3  nothing to do
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/crosbymichael/upgrade"
+	// register the supported target versions.
+	_ "github.com/crosbymichael/upgrade/v17_06_1"
+	_ "github.com/crosbymichael/upgrade/v17_09_0"
+)
+
+const (
+	exitOK          = 0
+	exitFailed      = 1
+	exitUsage       = 2
+	exitNothingToDo = 3
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(a *app, args []string) int
+}
+
+var commands = []command{
+	{"upgrade", "upgrade the state files of the given containers", upgradeCommand},
+	{"check", "report whether the given containers need an upgrade", checkCommand},
+	{"inspect", "print the upgraded state of the given containers without writing it", inspectCommand},
+	{"rollback", "restore the state files saved by the last upgrade, or by the given --generation", rollbackCommand},
+	{"backups", "list the backups of the state files of the given containers", backupsCommand},
+}
+
+type app struct {
+	flags          *flag.FlagSet
+	target         upgrade.Version
+	runcRoot       string
+	containerdRoot string
+	root           string
+	format         string
+	all            bool
+	dryRun         bool
+	preserve       bool
+	normalize      bool
+	capabilities   upgrade.CapabilityPolicy
+	strict         bool
+	procRoot       string
+	ignoreLiveness bool
+	dead           upgrade.DeadPolicy
+	workers        int
+	lockTimeout    time.Duration
+	refuseWriters  bool
+	retention      upgrade.Retention
+	marker         bool
+	skipValidation bool
+	allowInvalid   bool
+	generation     string
+	timeout        time.Duration
+	stdout         io.Writer
+	stderr         io.Writer
+}
+
+// containers returns the containers named on the command line, or all the
+// containers found in the state roots if --all was given.
+func (a *app) containers(ids []string) ([]upgrade.Container, []upgrade.Orphan, error) {
+	if a.all {
+		inv, err := upgrade.DiscoverFS(a.fs(), a.runcRoot, a.containerdRoot)
+		if err != nil {
+			return nil, nil, err
+		}
+		return inv.Containers, inv.Orphans, nil
+	}
+	var containers []upgrade.Container
+	for _, id := range ids {
+		c := upgrade.NewContainer(a.runcRoot, a.containerdRoot, id)
+		c.FS = a.fs()
+		containers = append(containers, c)
+	}
+	return containers, nil, nil
+}
+
+// fs returns the file system holding the state roots.
+func (a *app) fs() upgrade.FS {
+	if a.root == "" || a.root == "/" {
+		return upgrade.HostFS
+	}
+	return upgrade.RootFS(a.root)
+}
+
+const (
+	statusUpgraded     = "upgraded"
+	statusUnchanged    = "unchanged"
+	statusNeedsUpgrade = "needs-upgrade"
+	statusRestored     = "restored"
+	statusSkipped      = "skipped"
+	statusRemoved      = "removed"
+	statusBackedUp     = "backed-up"
+	statusNoBackup     = "no-backup"
+	statusOrphan       = "orphan"
+	statusFailed       = "failed"
+)
+
+type result struct {
+	ID       string                     `json:"id"`
+	Status   string                     `json:"status"`
+	Versions map[string]upgrade.Version `json:"versions,omitempty"`
+	Detail   string                     `json:"detail,omitempty"`
+	Files    []fileChange               `json:"files,omitempty"`
+	// Processes lists whether the state of each process was converted.
+	Processes []process `json:"processes,omitempty"`
+	// Backup is the generation of the backup taken or restored.
+	Backup  string   `json:"backup,omitempty"`
+	Backups []backup `json:"backups,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type process struct {
+	ID      string          `json:"id"`
+	Path    string          `json:"path"`
+	From    upgrade.Version `json:"from"`
+	Changed bool            `json:"changed"`
+}
+
+type backup struct {
+	Generation string    `json:"generation"`
+	Created    time.Time `json:"created"`
+}
+
+// fileChange is a state file changed by an upgrade.
+type fileChange struct {
+	Path  string          `json:"path"`
+	From  upgrade.Version `json:"from"`
+	To    upgrade.Version `json:"to"`
+	Patch json.RawMessage `json:"patch"`
+	// Unknown lists the fields that were not understood by the upgrade.
+	Unknown []string `json:"unknown,omitempty"`
+	// Losses lists the information that could not be converted.
+	Losses []string `json:"losses,omitempty"`
+	// Violations lists the parts of the converted file that do not follow
+	// the specification of its format.
+	Violations []string `json:"violations,omitempty"`
+	diff       upgrade.Diff
+}
+
+func newResult(id, status string, err error) result {
+	r := result{ID: id, Status: status}
+	if err != nil {
+		r.Status = statusFailed
+		r.Error = err.Error()
+	}
+	return r
+}
+
+func orphanResults(orphans []upgrade.Orphan) []result {
+	var results []result
+	for _, o := range orphans {
+		results = append(results, result{
+			ID:     o.ID,
+			Status: statusOrphan,
+			Detail: "missing " + strings.Join(o.Missing, ", "),
+		})
+	}
+	return results
+}
+
+// exitCode maps the results of a command to the process exit code.
+// changed is the status reported when a container was (or would be) modified.
+func exitCode(results []result, changed string) int {
+	code := exitNothingToDo
+	for _, r := range results {
+		switch r.Status {
+		case statusFailed:
+			return exitFailed
+		case changed:
+			code = exitOK
+		}
+	}
+	return code
+}
+
+func (a *app) printResults(results []result) error {
+	if a.format == "json" {
+		return a.printJSON(struct {
+			Results []result `json:"results"`
+		}{results})
+	}
+	w := tabwriter.NewWriter(a.stdout, 0, 8, 1, ' ', 0)
+	for _, r := range results {
+		detail := r.Detail
+		if r.Versions != nil {
+			var names []string
+			for name := range r.Versions {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var versions []string
+			for _, name := range names {
+				versions = append(versions, fmt.Sprintf("%s=%s", name, r.Versions[name]))
+			}
+			detail = strings.Join(versions, " ")
+		}
+		if detail == "" && r.Backup != "" {
+			detail = "backup " + r.Backup
+		}
+		if r.Error != "" {
+			detail = r.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.ID, r.Status, detail)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, r := range results {
+		for _, f := range r.Files {
+			fmt.Fprintf(a.stdout, "\n%s (%s -> %s)\n%s", f.Path, f.From, f.To, f.diff)
+			action := "dropped"
+			if a.preserve {
+				action = "preserved"
+			}
+			for _, field := range f.Unknown {
+				fmt.Fprintf(a.stdout, "? %s: unknown field %s\n", field, action)
+			}
+			for _, loss := range f.Losses {
+				fmt.Fprintf(a.stdout, "! %s\n", loss)
+			}
+			for _, v := range f.Violations {
+				fmt.Fprintf(a.stdout, "x %s\n", v)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *app) printJSON(v interface{}) error {
+	enc := json.NewEncoder(a.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (a *app) parse(name string, args []string) ([]string, bool) {
+	a.flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	a.flags.SetOutput(a.stderr)
+	var target string
+	a.flags.StringVar(&target, "target", defaultTarget(), "Docker version to upgrade the state files to")
+	a.flags.StringVar(&a.runcRoot, "runc-root", upgrade.DefaultRuncRoot, "root directory of the runc state")
+	a.flags.StringVar(&a.containerdRoot, "containerd-root", upgrade.DefaultContainerdRoot, "root directory of the libcontainerd state")
+	a.flags.StringVar(&a.root, "root", "/", "directory the state roots are relative to, such as the mount point of another system")
+	a.flags.StringVar(&a.format, "format", "text", "output format (text or json)")
+	a.flags.BoolVar(&a.all, "all", false, "operate on every container found in the state roots")
+	var dead, capabilities string
+	if name == "upgrade" {
+		a.flags.BoolVar(&a.dryRun, "dry-run", false, "print the changes without writing them")
+		a.flags.StringVar(&a.procRoot, "proc-root", upgrade.DefaultProcRoot, "mount point of procfs, used to check that containers are running")
+		a.flags.BoolVar(&a.ignoreLiveness, "ignore-liveness", false, "upgrade containers even if their init process is not running")
+		a.flags.StringVar(&dead, "dead", "skip", "what to do with containers whose init process is not running (skip or cleanup)")
+		a.flags.IntVar(&a.workers, "workers", runtime.NumCPU(), "number of containers upgraded concurrently")
+		a.flags.BoolVar(&a.refuseWriters, "refuse-writers", false, "fail instead of warning when a containerd-shim or runc process of a container is running")
+		a.flags.BoolVar(&a.marker, "marker", false, "record the version of the upgraded files next to them, so that later runs skip them")
+		a.flags.BoolVar(&a.skipValidation, "skip-validation", false, "write the upgraded files even if they do not agree with each other")
+		a.flags.BoolVar(&a.allowInvalid, "allow-invalid", false, "write the upgraded files even if they violate the specification of their format")
+		a.flags.IntVar(&a.retention.Keep, "keep-backups", 5, "number of backups kept for each container (0 for all)")
+		a.flags.DurationVar(&a.retention.MaxAge, "max-backup-age", 0, "age after which backups are removed, the last one excepted (0 for no limit)")
+		a.flags.DurationVar(&a.timeout, "timeout", 0, "time allowed for the whole upgrade, after which the containers not started yet fail (0 for no limit)")
+	}
+	if name == "rollback" {
+		a.flags.StringVar(&a.generation, "generation", "", "backup to restore, the last one if empty")
+	}
+	if name == "upgrade" || name == "rollback" {
+		a.flags.DurationVar(&a.lockTimeout, "lock-timeout", 10*time.Second, "how long to wait for another upgrade of a container to complete")
+	}
+	if name == "upgrade" || name == "inspect" {
+		a.flags.BoolVar(&a.preserve, "preserve-unknown", false, "keep the fields unknown to the upgrade instead of dropping them")
+		a.flags.BoolVar(&a.normalize, "normalize", false, "rewrite the upgraded files in a canonical form, such as merged seccomp rules")
+		a.flags.StringVar(&capabilities, "capabilities", "docker", "how to expand flat capability lists into capability sets (docker, no-inheritable or non-root-ambient)")
+	}
+	if name == "upgrade" || name == "inspect" || name == "check" {
+		a.flags.BoolVar(&a.strict, "strict", false, "fail on fields unknown to the upgrade")
+	}
+	a.flags.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: upgrade %s [OPTIONS] (--all | CONTAINER [CONTAINER...])\n\n", name)
+		a.flags.PrintDefaults()
+	}
+	if err := a.flags.Parse(args); err != nil {
+		return nil, false
+	}
+	if a.format != "text" && a.format != "json" {
+		fmt.Fprintf(a.stderr, "unsupported output format: %s\n", a.format)
+		return nil, false
+	}
+	if dead != "" {
+		var err error
+		if a.dead, err = upgrade.ParseDeadPolicy(dead); err != nil {
+			fmt.Fprintln(a.stderr, err)
+			return nil, false
+		}
+	}
+	if capabilities != "" {
+		var err error
+		if a.capabilities, err = upgrade.ParseCapabilityPolicy(capabilities); err != nil {
+			fmt.Fprintln(a.stderr, err)
+			return nil, false
+		}
+	}
+	a.target = upgrade.Version(target)
+	if !supported(a.target) {
+		fmt.Fprintf(a.stderr, "unsupported target version: %s (supported: %v)\n", target, upgrade.Targets())
+		return nil, false
+	}
+	if (a.flags.NArg() == 0) != a.all {
+		a.flags.Usage()
+		return nil, false
+	}
+	return a.flags.Args(), true
+}
+
+// defaultTarget is the newest version that state files can be upgraded to.
+func defaultTarget() string {
+	targets := upgrade.Targets()
+	if len(targets) == 0 {
+		return ""
+	}
+	return string(targets[len(targets)-1])
+}
+
+func supported(target upgrade.Version) bool {
+	for _, v := range upgrade.Targets() {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: upgrade COMMAND [OPTIONS] (--all | CONTAINER [CONTAINER...])")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.usage)
+	}
+	tw.Flush()
+}
+
+// run runs the command named by args[0] with the rest of args, and returns
+// the exit code.
+func run(a *app, args []string) int {
+	if len(args) < 1 {
+		usage(a.stderr)
+		return exitUsage
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(a, args[1:])
+		}
+	}
+	switch args[0] {
+	case "help", "-h", "--help":
+		usage(a.stdout)
+		return exitOK
+	}
+	fmt.Fprintf(a.stderr, "unknown command: %s\n", args[0])
+	usage(a.stderr)
+	return exitUsage
+}
+
+func main() {
+	os.Exit(run(&app{stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:]))
+}
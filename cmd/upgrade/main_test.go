@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+// id is the container of the fixtures.
+const id = "50f0834dd21e3e7a03f7373d2146d9026f7ebac96c6786015d78739cb299387f"
+
+// setup writes the state files of container id, as written by Docker version
+// v, in the default state roots under root. The file names are those of
+// ../../testfiles, without the version.
+func setup(t *testing.T, root, id string, v upgrade.Version) upgrade.Container {
+	c := upgrade.NewContainer(filepath.Join(root, upgrade.DefaultRuncRoot), filepath.Join(root, upgrade.DefaultContainerdRoot), id)
+	for _, d := range []struct{ path, name string }{
+		{c.State, "state.json"},
+		{c.Config, "config.json"},
+		{c.Process, "process.json"},
+	} {
+		b, err := ioutil.ReadFile(filepath.Join("..", "..", "testfiles", d.name+"-"+string(v)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(d.path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(d.path, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// runCommand runs the command line args against the state roots under root,
+// and returns the exit code and the output.
+func runCommand(root string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	a := &app{stdout: &stdout, stderr: &stderr}
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				args = append([]string{args[0], "--root", root}, args[1:]...)
+				break
+			}
+		}
+	}
+	code := run(a, args)
+	return code, stdout.String(), stderr.String()
+}
+
+func tempRoot(t *testing.T) string {
+	root, err := ioutil.TempDir("", "upgrade-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no process is running in the containers.
+	if err := os.Mkdir(filepath.Join(root, "proc"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestExitCodes(t *testing.T) {
+	for _, d := range [...]struct {
+		name    string
+		version upgrade.Version
+		setup   func(c upgrade.Container) error
+		args    []string
+		code    int
+	}{
+		{"no command", "", nil, nil, exitUsage},
+		{"help", "", nil, []string{"help"}, exitOK},
+		{"unknown command", "", nil, []string{"convert", id}, exitUsage},
+		{"no container", "", nil, []string{"upgrade"}, exitUsage},
+		{"all and container", "", nil, []string{"upgrade", "--all", id}, exitUsage},
+		{"unknown flag", "", nil, []string{"check", "--dry-run", id}, exitUsage},
+		{"unknown format", "", nil, []string{"check", "--format", "yaml", id}, exitUsage},
+		{"unknown target", "", nil, []string{"check", "--target", "1.12", id}, exitUsage},
+		{"unknown dead policy", "", nil, []string{"upgrade", "--dead", "bury", id}, exitUsage},
+		{"unknown capability policy", "", nil, []string{"upgrade", "--capabilities", "all", id}, exitUsage},
+
+		{"check 17.03", upgrade.V17_03, nil, []string{"check", id}, exitOK},
+		{"check all", upgrade.V17_03, nil, []string{"check", "--all"}, exitOK},
+		{"check current", upgrade.V17_06_1, nil, []string{"check", "--target", "17.06.1", id}, exitNothingToDo},
+		{"check missing", "", nil, []string{"check", id}, exitFailed},
+
+		{"upgrade 17.03", upgrade.V17_03, nil, []string{"upgrade", "--ignore-liveness", id}, exitOK},
+		{"upgrade 17.06.0", upgrade.V17_06_0, nil, []string{"upgrade", "--ignore-liveness", "--normalize", "--target", "17.06.1", id}, exitOK},
+		{"dry run", upgrade.V17_03, nil, []string{"upgrade", "--ignore-liveness", "--dry-run", id}, exitOK},
+		{"upgrade current", upgrade.V17_06_1, nil, []string{"upgrade", "--ignore-liveness", "--target", "17.06.1", id}, exitNothingToDo},
+		{"upgrade dead", upgrade.V17_03, nil, []string{"upgrade", id}, exitNothingToDo},
+		{"upgrade invalid", upgrade.V17_03, func(c upgrade.Container) error {
+			return ioutil.WriteFile(c.State, []byte("{"), 0600)
+		}, []string{"upgrade", "--ignore-liveness", id}, exitFailed},
+		{"upgrade strict", upgrade.V17_06_0, func(c upgrade.Container) error {
+			b, err := ioutil.ReadFile(c.Config)
+			if err != nil {
+				return err
+			}
+			b = bytes.Replace(b, []byte(`{"ociVersion"`), []byte(`{"unknown":true,"ociVersion"`), 1)
+			return ioutil.WriteFile(c.Config, b, 0600)
+		}, []string{"upgrade", "--ignore-liveness", "--strict", id}, exitFailed},
+		{"upgrade orphan", upgrade.V17_03, func(c upgrade.Container) error {
+			return os.Remove(c.Config)
+		}, []string{"upgrade", "--ignore-liveness", "--all"}, exitNothingToDo},
+
+		{"rollback without backup", upgrade.V17_03, nil, []string{"rollback", id}, exitNothingToDo},
+		{"backups without backup", upgrade.V17_03, nil, []string{"backups", id}, exitNothingToDo},
+		{"inspect", upgrade.V17_03, nil, []string{"inspect", id}, exitOK},
+	} {
+		t.Run(d.name, func(t *testing.T) {
+			root := tempRoot(t)
+			defer os.RemoveAll(root)
+			if d.version != "" {
+				c := setup(t, root, id, d.version)
+				if d.setup != nil {
+					if err := d.setup(c); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			args := d.args
+			if len(args) > 0 && args[0] == "upgrade" {
+				args = append([]string{"upgrade", "--proc-root", filepath.Join(root, "proc")}, args[1:]...)
+			}
+			code, stdout, stderr := runCommand(root, args...)
+			if code != d.code {
+				t.Fatalf("%v: exit code %d, expected %d\n%s%s", d.args, code, d.code, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestUpgradeJSON(t *testing.T) {
+	root := tempRoot(t)
+	defer os.RemoveAll(root)
+	c := setup(t, root, id, upgrade.V17_03)
+	original, err := ioutil.ReadFile(c.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	procRoot := filepath.Join(root, "proc")
+	decode := func(out string) []result {
+		var v struct {
+			Results []result `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(out), &v); err != nil {
+			t.Fatalf("%v: %s", err, out)
+		}
+		return v.Results
+	}
+
+	code, out, _ := runCommand(root, "upgrade", "--format", "json", "--proc-root", procRoot, "--ignore-liveness", "--target", "17.06.1", id)
+	if code != exitOK {
+		t.Fatalf("upgrade: exit code %d\n%s", code, out)
+	}
+	results := decode(out)
+	if len(results) != 1 {
+		t.Fatalf("upgrade: %d results", len(results))
+	}
+	r := results[0]
+	if r.ID != id || r.Status != statusUpgraded || r.Backup == "" || len(r.Files) != 3 {
+		t.Fatalf("upgrade: %+v", r)
+	}
+	for _, f := range r.Files {
+		// the states of 17.03 and 17.05 cannot be told apart.
+		if (f.From != upgrade.V17_03 && f.From != upgrade.V17_05) || f.To != upgrade.V17_06_1 {
+			t.Fatalf("upgrade %s: %s -> %s", f.Path, f.From, f.To)
+		}
+		var patch []map[string]interface{}
+		if err := json.Unmarshal(f.Patch, &patch); err != nil || len(patch) == 0 {
+			t.Fatalf("upgrade %s: patch %s: %v", f.Path, f.Patch, err)
+		}
+	}
+	if len(r.Processes) != 1 || r.Processes[0].ID != "init" || !r.Processes[0].Changed {
+		t.Fatalf("upgrade: processes %+v", r.Processes)
+	}
+
+	code, out, _ = runCommand(root, "upgrade", "--format", "json", "--proc-root", procRoot, "--ignore-liveness", "--target", "17.06.1", id)
+	if r := decode(out); code != exitNothingToDo || r[0].Status != statusUnchanged || len(r[0].Files) != 0 {
+		t.Fatalf("upgrade again: exit code %d\n%s", code, out)
+	}
+
+	code, out, _ = runCommand(root, "backups", "--format", "json", id)
+	if r := decode(out); code != exitOK || len(r[0].Backups) != 1 || r[0].Backups[0].Generation != results[0].Backup {
+		t.Fatalf("backups: exit code %d\n%s", code, out)
+	}
+
+	code, out, _ = runCommand(root, "rollback", "--format", "json", id)
+	if r := decode(out); code != exitOK || r[0].Status != statusRestored {
+		t.Fatalf("rollback: exit code %d\n%s", code, out)
+	}
+	restored, err := ioutil.ReadFile(c.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored, original) {
+		t.Fatalf("rollback: config not restored")
+	}
+
+	code, out, _ = runCommand(root, "check", "--format", "json", id)
+	if r := decode(out); code != exitOK || r[0].Status != statusNeedsUpgrade || r[0].Versions["config.json"] != upgrade.V17_03 {
+		t.Fatalf("check: exit code %d\n%s", code, out)
+	}
+}
+
+func TestUpgradeText(t *testing.T) {
+	root := tempRoot(t)
+	defer os.RemoveAll(root)
+	setup(t, root, id, upgrade.V17_03)
+	code, out, _ := runCommand(root, "upgrade", "--dry-run", "--proc-root", filepath.Join(root, "proc"), "--ignore-liveness", id)
+	if code != exitOK {
+		t.Fatalf("exit code %d\n%s", code, out)
+	}
+	lines := strings.Split(out, "\n")
+	if fields := strings.Fields(lines[0]); len(fields) < 2 || fields[0] != id || fields[1] != statusNeedsUpgrade {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if !strings.Contains(out, "config.json (17.03 -> 17.09.0)") {
+		t.Fatalf("no changes to config.json:\n%s", out)
+	}
+}
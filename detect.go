@@ -0,0 +1,355 @@
+package upgrade
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Confidence tells how reliable the result of DetectVersion is.
+type Confidence int
+
+const (
+	// ConfidenceNone means that no known version matches: either nothing
+	// recognisable was found, or the evidence is contradictory.
+	ConfidenceNone Confidence = iota
+	// ConfidenceLow means that several versions write the same format, in
+	// which case the newest of them is returned.
+	ConfidenceLow
+	// ConfidenceHigh means that a single version matches.
+	ConfidenceHigh
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceHigh:
+		return "high"
+	}
+	return "none"
+}
+
+// Evidence is an observation about a state file which restricts the versions
+// that could have written it.
+type Evidence struct {
+	// Path is the JSON path of the field that was looked at.
+	Path string
+	// Observed describes what was found there, such as "string" or "absent".
+	Observed string
+	// Versions are the versions consistent with the observation.
+	Versions []Version
+}
+
+var (
+	v17_03To17_05     = []Version{V17_03, V17_05}
+	v17_03To17_06_0   = []Version{V17_03, V17_05, V17_06_0}
+	v17_03To17_06_1   = []Version{V17_03, V17_05, V17_06_0, V17_06_1}
+	v17_06_0To17_06_1 = []Version{V17_06_0, V17_06_1}
+	v17_06_0To17_09_0 = []Version{V17_06_0, V17_06_1, V17_09_0}
+	v17_06_1Only      = []Version{V17_06_1}
+	v17_06_1To17_09_0 = []Version{V17_06_1, V17_09_0}
+	v17_09_0Only      = []Version{V17_09_0}
+)
+
+// A probe looks at a decoded document and returns nil if it found nothing
+// conclusive.
+type probe func(doc map[string]interface{}) *Evidence
+
+var probes = map[Kind][]probe{
+	KindState: {
+		typeProbe(".init_process_start", map[string][]Version{
+			"string": v17_03To17_06_0,
+			"number": v17_06_1To17_09_0,
+		}),
+		typeProbe(".config.capabilities", map[string][]Version{
+			"array":  v17_03To17_05,
+			"object": v17_06_0To17_09_0,
+		}),
+		typeProbe(".rootless", map[string][]Version{
+			"absent": v17_03To17_05,
+			"bool":   v17_06_0To17_09_0,
+		}),
+		specReleaseProbe(".config.version"),
+	},
+	KindConfig: {
+		typeProbe(".process.capabilities", map[string][]Version{
+			"array":  v17_03To17_05,
+			"object": v17_06_0To17_09_0,
+		}),
+		typeProbe(".linux.resources.blockIO.blkioWeight", map[string][]Version{
+			"number": v17_03To17_06_0,
+		}),
+		typeProbe(".linux.resources.blockIO.weight", map[string][]Version{
+			"number": v17_06_1To17_09_0,
+		}),
+		typeProbe(".process.oomScoreAdj", map[string][]Version{
+			"number": v17_06_1To17_09_0,
+		}),
+		specReleaseProbe(".ociVersion"),
+		func(doc map[string]interface{}) *Evidence {
+			syscalls := seccompSyscalls(doc)
+			for _, s := range syscalls {
+				if _, ok := s["name"]; ok {
+					return &Evidence{".linux.seccomp.syscalls[].name", "string", v17_03To17_05}
+				}
+				if _, ok := s["names"]; ok {
+					return &Evidence{".linux.seccomp.syscalls[].names", "array", v17_06_0To17_09_0}
+				}
+			}
+			return nil
+		},
+	},
+	// 17.09.0 writes the same process.json as 17.06.1, which is what such
+	// files are recognized as: they need no conversion between the two.
+	KindProcess: {
+		typeProbe(".capabilities", map[string][]Version{
+			"array":  v17_03To17_05,
+			"object": v17_06_0To17_06_1,
+		}),
+		// the earlier versions always write a console size, empty
+		// unless set; 17.06.1 omits it if empty.
+		typeProbe(".consoleSize", map[string][]Version{
+			"object": v17_03To17_06_0,
+			"absent": v17_06_1Only,
+		}),
+	},
+}
+
+// hints look at values rather than at the shape of the document, or at
+// fields that the upgrade does not remove. They tell which version created
+// the container, but they are carried over as is when the file is upgraded.
+// Hints are therefore only used to choose between the versions allowed by
+// the probes, never to contradict them.
+var hints = map[Kind][]probe{
+	KindState: {
+		specVersionProbe(".config.version"),
+		func(doc map[string]interface{}) *Evidence {
+			const path = ".config.cgroups.memory_swappiness"
+			v, ok := lookup(doc, path)
+			if !ok {
+				return nil
+			}
+			switch observed := jsonString(v); observed {
+			case "-1":
+				// runc used to store it as an int64.
+				return &Evidence{path, observed, v17_03To17_05}
+			case "18446744073709551615":
+				return &Evidence{path, observed, []Version{V17_06_0}}
+			case "null":
+				return &Evidence{path, observed, v17_06_1To17_09_0}
+			}
+			return nil
+		},
+	},
+	KindConfig: {
+		specVersionProbe(".ociVersion"),
+		// 17.06.1 does not write these fields, but the upgraded files
+		// keep the platform and the console sizes that are set.
+		typeProbe(".process.consoleSize", map[string][]Version{
+			"object": v17_03To17_06_0,
+			"absent": v17_06_1To17_09_0,
+		}),
+		typeProbe(".platform", map[string][]Version{
+			"object": v17_03To17_06_0,
+			"absent": v17_06_1To17_09_0,
+		}),
+		typeProbe(".linux.resources.oomScoreAdj", map[string][]Version{
+			"number": v17_03To17_06_0,
+		}),
+		func(doc map[string]interface{}) *Evidence {
+			// preadv2 and pwritev2 were allowed by the default profile of
+			// 17.05 and 17.06.0 only. Only look for them in profiles
+			// derived from the default one, which always allows pwritev.
+			names := make(map[string]bool)
+			for _, s := range seccompSyscalls(doc) {
+				if name, ok := s["name"].(string); ok {
+					names[name] = true
+				}
+				if list, ok := s["names"].([]interface{}); ok {
+					for _, name := range list {
+						if name, ok := name.(string); ok {
+							names[name] = true
+						}
+					}
+				}
+			}
+			const path = ".linux.seccomp.syscalls"
+			switch {
+			case !names["pwritev"]:
+				return nil
+			case names["preadv2"]:
+				return &Evidence{path, "preadv2 allowed", []Version{V17_05, V17_06_0}}
+			default:
+				return &Evidence{path, "preadv2 not allowed", []Version{V17_03, V17_06_1, V17_09_0}}
+			}
+		},
+	},
+}
+
+// DetectVersion guesses which Docker release wrote the state file b of the
+// given kind, by looking at the shape of the JSON. It returns the evidence
+// that led to the result.
+//
+// The process states that Docker 17.06.1 writes for a process with a console
+// size, or that the upgrade leaves with one, cannot be told from those of
+// 17.06.0, as which they are recognized: converting them to 17.06.1 changes
+// nothing.
+func DetectVersion(kind Kind, b []byte) (Version, Confidence, []Evidence) {
+	var doc map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return Unknown, ConfidenceNone, []Evidence{{Path: ".", Observed: err.Error()}}
+	}
+
+	candidates := versions
+	var evidence []Evidence
+	// missing fields alone are not enough to recognise a file.
+	present := false
+	for _, p := range probes[kind] {
+		e := p(doc)
+		if e == nil {
+			continue
+		}
+		present = present || e.Observed != "absent"
+		evidence = append(evidence, *e)
+		candidates = intersect(candidates, e.Versions)
+		if len(candidates) == 0 {
+			return Unknown, ConfidenceNone, evidence
+		}
+	}
+	for _, p := range hints[kind] {
+		e := p(doc)
+		if e == nil || len(intersect(candidates, e.Versions)) == 0 {
+			continue
+		}
+		evidence = append(evidence, *e)
+		candidates = intersect(candidates, e.Versions)
+	}
+	switch {
+	case !present:
+		return Unknown, ConfidenceNone, evidence
+	case len(candidates) == 1:
+		return candidates[0], ConfidenceHigh, evidence
+	}
+	return candidates[len(candidates)-1], ConfidenceLow, evidence
+}
+
+func intersect(a, b []Version) []Version {
+	var r []Version
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				r = append(r, x)
+				break
+			}
+		}
+	}
+	return r
+}
+
+// typeProbe returns a probe which looks at the JSON type of the value at
+// path, "absent" if there is none.
+func typeProbe(path string, types map[string][]Version) probe {
+	return func(doc map[string]interface{}) *Evidence {
+		observed := "absent"
+		if v, ok := lookup(doc, path); ok {
+			observed = jsonType(v)
+		}
+		if versions, ok := types[observed]; ok {
+			return &Evidence{path, observed, versions}
+		}
+		return nil
+	}
+}
+
+// specVersionProbe returns a probe which looks at the runtime-spec version
+// written at path.
+func specVersionProbe(path string) probe {
+	return func(doc map[string]interface{}) *Evidence {
+		v, ok := lookup(doc, path)
+		if !ok {
+			return nil
+		}
+		s, _ := v.(string)
+		switch {
+		case strings.HasPrefix(s, "1.0.0-rc2"):
+			return &Evidence{path, s, v17_03To17_05}
+		case strings.HasPrefix(s, "1.0.0-rc5"):
+			return &Evidence{path, s, v17_06_0To17_06_1}
+		}
+		return nil
+	}
+}
+
+// specReleaseProbe returns a probe which tells the files written with the
+// final runtime-spec v1.0.0, from 17.09.0 on, from the earlier ones. The
+// files upgraded to 17.06.1 keep their original version, so only the release
+// itself is conclusive.
+func specReleaseProbe(path string) probe {
+	return func(doc map[string]interface{}) *Evidence {
+		v, ok := lookup(doc, path)
+		if !ok {
+			return nil
+		}
+		s, _ := v.(string)
+		if s == "1.0.0" {
+			return &Evidence{path, s, v17_09_0Only}
+		}
+		return &Evidence{path, s, v17_03To17_06_1}
+	}
+}
+
+func seccompSyscalls(doc map[string]interface{}) []map[string]interface{} {
+	v, _ := lookup(doc, ".linux.seccomp.syscalls")
+	list, _ := v.([]interface{})
+	var syscalls []map[string]interface{}
+	for _, s := range list {
+		if s, ok := s.(map[string]interface{}); ok {
+			syscalls = append(syscalls, s)
+		}
+	}
+	return syscalls
+}
+
+// lookup returns the value at path, a dot-separated list of object keys.
+func lookup(doc map[string]interface{}, path string) (interface{}, bool) {
+	var v interface{} = doc
+	for _, key := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
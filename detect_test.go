@@ -0,0 +1,97 @@
+package upgrade
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDetectVersion(t *testing.T) {
+	for _, d := range [...]struct {
+		filename   string
+		kind       Kind
+		version    Version
+		confidence Confidence
+	}{
+		// 17.03 and 17.05 write the same state.json and process.json.
+		{"testfiles/state.json-17.03", KindState, V17_05, ConfidenceLow},
+		{"testfiles/state.json-17.05", KindState, V17_05, ConfidenceLow},
+		{"testfiles/state.json-17.06.0", KindState, V17_06_0, ConfidenceHigh},
+		{"testfiles/state.json-17.06.1", KindState, V17_06_1, ConfidenceHigh},
+		{"testfiles/config.json-17.03", KindConfig, V17_03, ConfidenceHigh},
+		{"testfiles/config.json-17.05", KindConfig, V17_05, ConfidenceHigh},
+		{"testfiles/config.json-17.06.0", KindConfig, V17_06_0, ConfidenceHigh},
+		{"testfiles/config.json-17.06.1", KindConfig, V17_06_1, ConfidenceHigh},
+		{"testfiles/process.json-17.03", KindProcess, V17_05, ConfidenceLow},
+		{"testfiles/process.json-17.05", KindProcess, V17_05, ConfidenceLow},
+		{"testfiles/process.json-17.06.0", KindProcess, V17_06_0, ConfidenceHigh},
+		{"testfiles/process.json-17.06.1", KindProcess, V17_06_1, ConfidenceHigh},
+	} {
+		content, err := ioutil.ReadFile(d.filename)
+		if err != nil {
+			t.Fatalf("readfile %s: %v", d.filename, err)
+		}
+		version, confidence, evidence := DetectVersion(d.kind, content)
+		if version != d.version || confidence != d.confidence {
+			t.Fatalf("detect %s: %s (%s) | %s (%s), evidence: %v", d.filename, d.version, d.confidence, version, confidence, evidence)
+		}
+		if len(evidence) == 0 {
+			t.Fatalf("detect %s: no evidence", d.filename)
+		}
+	}
+}
+
+// TestDetectVersionSpecRelease checks that the files written with the final
+// runtime-spec, which otherwise look like those of 17.06.1, are recognized.
+func TestDetectVersionSpecRelease(t *testing.T) {
+	for _, d := range [...]struct {
+		filename string
+		kind     Kind
+		old, new string
+	}{
+		{"testfiles/state.json-17.06.1", KindState, `"version":"1.0.0-rc5"`, `"version":"1.0.0"`},
+		{"testfiles/config.json-17.06.1", KindConfig, `"ociVersion":"1.0.0-rc5-dev"`, `"ociVersion":"1.0.0"`},
+	} {
+		content, err := ioutil.ReadFile(d.filename)
+		if err != nil {
+			t.Fatalf("readfile %s: %v", d.filename, err)
+		}
+		content = bytes.Replace(content, []byte(d.old), []byte(d.new), 1)
+		version, confidence, evidence := DetectVersion(d.kind, content)
+		if version != V17_09_0 || confidence != ConfidenceHigh {
+			t.Fatalf("detect %s: %s (%s), evidence: %v", d.filename, version, confidence, evidence)
+		}
+	}
+}
+
+func TestDetectVersionContradiction(t *testing.T) {
+	for _, d := range [...]struct {
+		kind    Kind
+		content string
+	}{
+		{KindState, `{"init_process_start": 1234, "config": {"capabilities": ["CAP_KILL"]}}`},
+		{KindProcess, `{"capabilities": ["CAP_KILL"]}`},
+		{KindConfig, `{}`},
+		{KindConfig, `not json`},
+	} {
+		version, confidence, evidence := DetectVersion(d.kind, []byte(d.content))
+		if version != Unknown || confidence != ConfidenceNone {
+			t.Fatalf("detect %s: expected unknown version, got %s (%s), evidence: %v", d.content, version, confidence, evidence)
+		}
+	}
+}
+
+// TestDetectVersionUpgraded checks that the files written by the upgrade are
+// recognized, although they keep fields that 17.06.1 does not write.
+func TestDetectVersionUpgraded(t *testing.T) {
+	const config = `{
+		"ociVersion": "1.0.0-rc2-dev",
+		"platform": {"os": "linux", "arch": "amd64"},
+		"process": {"consoleSize": {"height": 0, "width": 0}, "capabilities": {"bounding": ["CAP_KILL"]}},
+		"linux": {"resources": {"blockIO": {"weight": 0}}}
+	}`
+	version, confidence, evidence := DetectVersion(KindConfig, []byte(config))
+	if version != V17_06_1 || confidence != ConfidenceHigh {
+		t.Fatalf("detect upgraded config: %s (%s), evidence: %v", version, confidence, evidence)
+	}
+}
@@ -0,0 +1,165 @@
+package upgrade
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Op is the kind of a Change, named after the RFC 6902 operations.
+type Op string
+
+const (
+	OpAdd     Op = "add"
+	OpRemove  Op = "remove"
+	OpReplace Op = "replace"
+)
+
+// Change is a difference between two JSON documents at a single location.
+type Change struct {
+	Op Op `json:"op"`
+	// Path is the location of the change, e.g. ".process.capabilities"
+	// or ".linux.seccomp.syscalls[3]".
+	Path string `json:"path"`
+	// Pointer is the RFC 6901 JSON Pointer of the change.
+	Pointer string `json:"pointer"`
+	// Old is the value removed or replaced, New the value added or
+	// replacing it.
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// Diff is the list of changes turning a JSON document into another one,
+// ordered by path.
+type Diff []Change
+
+// DiffJSON compares two JSON documents. Objects are compared key by key and
+// arrays of the same length element by element; any other difference,
+// including a change of type, replaces the whole value.
+func DiffJSON(old, new []byte) (Diff, error) {
+	a, err := decodeJSON(old)
+	if err != nil {
+		return nil, err
+	}
+	b, err := decodeJSON(new)
+	if err != nil {
+		return nil, err
+	}
+	var d Diff
+	d.compare(nil, a, b)
+	return d, nil
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// compare appends the changes turning a into b, found at path.
+func (d *Diff) compare(path Field, a, b interface{}) {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range a {
+			keys[k] = true
+		}
+		for k := range b {
+			keys[k] = true
+		}
+		var sorted []string
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			av, inA := a[k]
+			bv, inB := b[k]
+			p := path.child(k)
+			switch {
+			case !inB:
+				d.add(OpRemove, p, av, nil)
+			case !inA:
+				d.add(OpAdd, p, nil, bv)
+			default:
+				d.compare(p, av, bv)
+			}
+		}
+		return
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			break
+		}
+		for i := range a {
+			d.compare(path.child(i), a[i], b[i])
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		d.add(OpReplace, path, a, b)
+	}
+}
+
+func (d *Diff) add(op Op, path Field, old, new interface{}) {
+	*d = append(*d, Change{Op: op, Path: path.String(), Pointer: path.Pointer(), Old: old, New: new})
+}
+
+// String renders d as one line per change: "+" for added values, "-" for
+// removed values and "~" for replaced values.
+func (d Diff) String() string {
+	var buf bytes.Buffer
+	for _, c := range d {
+		switch c.Op {
+		case OpAdd:
+			fmt.Fprintf(&buf, "+ %s: %s\n", c.Path, jsonText(c.New))
+		case OpRemove:
+			fmt.Fprintf(&buf, "- %s: %s\n", c.Path, jsonText(c.Old))
+		case OpReplace:
+			fmt.Fprintf(&buf, "~ %s: %s -> %s\n", c.Path, jsonText(c.Old), jsonText(c.New))
+		}
+	}
+	return buf.String()
+}
+
+func jsonText(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+type patchOp struct {
+	Op    Op               `json:"op"`
+	Path  string           `json:"path"`
+	Value *json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch returns d as an RFC 6902 JSON Patch.
+func (d Diff) JSONPatch() ([]byte, error) {
+	ops := []patchOp{}
+	for _, c := range d {
+		op := patchOp{Op: c.Op, Path: c.Pointer}
+		if c.Op != OpRemove {
+			b, err := json.Marshal(c.New)
+			if err != nil {
+				return nil, err
+			}
+			raw := json.RawMessage(b)
+			op.Value = &raw
+		}
+		ops = append(ops, op)
+	}
+	return json.Marshal(ops)
+}
@@ -0,0 +1,46 @@
+package upgrade
+
+import (
+	"testing"
+)
+
+func TestDiffJSON(t *testing.T) {
+	old := `{"init_process_start":"123","platform":{"os":"linux"},"process":{"capabilities":["CAP_KILL"]},"args":["a","b"],"a/b~":1}`
+	new := `{"init_process_start":123,"process":{"capabilities":{"bounding":["CAP_KILL"]}},"args":["a","c"],"a/b~":1,"rootless":false}`
+	d, err := DiffJSON([]byte(old), []byte(new))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `~ .args[1]: "b" -> "c"
+~ .init_process_start: "123" -> 123
+- .platform: {"os":"linux"}
+~ .process.capabilities: ["CAP_KILL"] -> {"bounding":["CAP_KILL"]}
++ .rootless: false
+`
+	if s := d.String(); s != expected {
+		t.Fatalf("text:\n%s|\n%s", expected, s)
+	}
+	patch, err := d.JSONPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `[{"op":"replace","path":"/args/1","value":"c"},` +
+		`{"op":"replace","path":"/init_process_start","value":123},` +
+		`{"op":"remove","path":"/platform"},` +
+		`{"op":"replace","path":"/process/capabilities","value":{"bounding":["CAP_KILL"]}},` +
+		`{"op":"add","path":"/rootless","value":false}]`
+	if string(patch) != expected {
+		t.Fatalf("patch:\n%s |\n%s", expected, patch)
+	}
+
+	d, err = DiffJSON([]byte(`{"a/b~":[1]}`), []byte(`{"a/b~":[1,2]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d) != 1 || d[0].Pointer != "/a~1b~0" || d[0].Op != OpReplace {
+		t.Fatalf("escaped pointer: %+v", d)
+	}
+	if d, err := DiffJSON([]byte(old), []byte(old)); err != nil || len(d) != 0 {
+		t.Fatalf("same document: %v %v", d, err)
+	}
+}
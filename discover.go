@@ -0,0 +1,227 @@
+// Package upgrade locates the on-disk state of containers kept alive across a
+// Docker engine upgrade.
+package upgrade
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Default locations of the runc and libcontainerd state of Docker 17.x.
+const (
+	DefaultRuncRoot       = "/run/docker/runtime-runc/moby"
+	DefaultContainerdRoot = "/var/run/docker/libcontainerd"
+)
+
+// Names of the state files inside a container directory.
+const (
+	StateFile   = "state.json"
+	ConfigFile  = "config.json"
+	ProcessFile = "init-process.json"
+	// ProcessSuffix ends the names of the libcontainerd state files of
+	// all the processes of a container: the exec processes started by
+	// docker exec are named after their exec ID.
+	ProcessSuffix = "-process.json"
+)
+
+// Container holds the paths of the state files of a single container.
+type Container struct {
+	ID string
+	// State is the runc state.json.
+	State string
+	// Config is the libcontainerd config.json.
+	Config string
+	// Process is the libcontainerd init-process.json.
+	Process string
+	// Execs are the libcontainerd state files of the exec processes, next
+	// to Process. The functions reading the state files find them again
+	// with FindExecs, as execs come and go.
+	Execs []string
+	// FS holds the state files. It is the file system of the host if nil.
+	FS FS
+}
+
+// NewContainer returns the expected location of the state files of the
+// container id.
+func NewContainer(runcRoot, containerdRoot, id string) Container {
+	return Container{
+		ID:      id,
+		State:   filepath.Join(runcRoot, id, StateFile),
+		Config:  filepath.Join(containerdRoot, id, ConfigFile),
+		Process: filepath.Join(containerdRoot, id, ProcessFile),
+	}
+}
+
+func (c Container) fs() FS {
+	if c.FS == nil {
+		return HostFS
+	}
+	return c.FS
+}
+
+// Files returns the paths of all the state files of c.
+func (c Container) Files() []string {
+	return append([]string{c.State, c.Config, c.Process}, c.Execs...)
+}
+
+// FindExecs returns the paths of the state files of the exec processes of c,
+// sorted.
+func FindExecs(c Container) ([]string, error) {
+	dir := filepath.Dir(c.Process)
+	entries, err := c.fs().ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var execs []string
+	for _, e := range entries {
+		if e.Name() == filepath.Base(c.Process) || !strings.HasSuffix(e.Name(), ProcessSuffix) || !e.Mode().IsRegular() {
+			continue
+		}
+		execs = append(execs, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(execs)
+	return execs, nil
+}
+
+// withExecs returns c with the exec processes currently found.
+func (c Container) withExecs() (Container, error) {
+	var err error
+	c.Execs, err = FindExecs(c)
+	return c, err
+}
+
+// isExec reports whether path is the state file of an exec process of c.
+func (c Container) isExec(path string) bool {
+	return path != c.Process && filepath.Dir(path) == filepath.Dir(c.Process) && strings.HasSuffix(path, ProcessSuffix)
+}
+
+// ProcessID returns the libcontainerd name of the process whose state is in
+// path: "init" for the init process, the exec ID for the others.
+func ProcessID(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ProcessSuffix)
+}
+
+// Orphan is a container with some of its state files missing, for example a
+// runc state.json without a matching libcontainerd config.json. It cannot be
+// upgraded.
+type Orphan struct {
+	ID      string
+	Found   []string
+	Missing []string
+}
+
+func (o Orphan) String() string {
+	return fmt.Sprintf("%s: missing %s", o.ID, strings.Join(o.Missing, ", "))
+}
+
+// Inventory is the result of Discover.
+type Inventory struct {
+	Containers []Container
+	Orphans    []Orphan
+}
+
+// Discover walks runcRoot and containerdRoot and pairs the state files of
+// every container found in either of them. Containers are returned sorted by
+// ID.
+func Discover(runcRoot, containerdRoot string) (*Inventory, error) {
+	return DiscoverFS(nil, runcRoot, containerdRoot)
+}
+
+// DiscoverFS is like Discover, but walks fs instead of the file system of the
+// host. The containers returned use fs.
+func DiscoverFS(fs FS, runcRoot, containerdRoot string) (*Inventory, error) {
+	scanFS := fs
+	if scanFS == nil {
+		scanFS = HostFS
+	}
+	runc, err := scan(scanFS, runcRoot, StateFile)
+	if err != nil {
+		return nil, err
+	}
+	containerd, err := scan(scanFS, containerdRoot, ConfigFile, ProcessFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(runc)+len(containerd))
+	for id := range runc {
+		ids = append(ids, id)
+	}
+	for id := range containerd {
+		if _, ok := runc[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	inv := &Inventory{}
+	for _, id := range ids {
+		c := NewContainer(runcRoot, containerdRoot, id)
+		c.FS = fs
+		o := Orphan{ID: id}
+		for _, f := range []struct {
+			path    string
+			present bool
+		}{
+			{c.State, runc[id][StateFile]},
+			{c.Config, containerd[id][ConfigFile]},
+			{c.Process, containerd[id][ProcessFile]},
+		} {
+			if f.present {
+				o.Found = append(o.Found, f.path)
+			} else {
+				o.Missing = append(o.Missing, f.path)
+			}
+		}
+		if o.Missing != nil {
+			inv.Orphans = append(inv.Orphans, o)
+			continue
+		}
+		if c, err = c.withExecs(); err != nil {
+			return nil, err
+		}
+		inv.Containers = append(inv.Containers, c)
+	}
+	return inv, nil
+}
+
+// scan returns, for every subdirectory of root containing at least one of the
+// given files, which of those files are present.
+func scan(fs FS, root string, names ...string) (map[string]map[string]bool, error) {
+	entries, err := fs.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	found := make(map[string]map[string]bool)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		for _, name := range names {
+			fi, err := fs.Stat(filepath.Join(root, e.Name(), name))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			if !fi.Mode().IsRegular() {
+				continue
+			}
+			if found[e.Name()] == nil {
+				found[e.Name()] = make(map[string]bool)
+			}
+			found[e.Name()][name] = true
+		}
+	}
+	return found, nil
+}
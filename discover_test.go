@@ -0,0 +1,103 @@
+package upgrade
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func touch(t *testing.T, path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("mkdir %s: %v", path, err)
+	}
+	if err := ioutil.WriteFile(path, []byte("{}"), 0600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	root, err := ioutil.TempDir("", "upgrade-discover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	runcRoot := filepath.Join(root, "runc")
+	containerdRoot := filepath.Join(root, "libcontainerd")
+
+	for _, id := range []string{"b", "a", "no-config", "no-process"} {
+		touch(t, filepath.Join(runcRoot, id, StateFile))
+	}
+	for _, id := range []string{"a", "b", "no-process", "no-state"} {
+		touch(t, filepath.Join(containerdRoot, id, ConfigFile))
+	}
+	for _, id := range []string{"a", "b", "no-state"} {
+		touch(t, filepath.Join(containerdRoot, id, ProcessFile))
+	}
+	for _, name := range []string{"e2" + ProcessSuffix, "e1" + ProcessSuffix, "e1-stdin"} {
+		touch(t, filepath.Join(containerdRoot, "b", name))
+	}
+	// neither state nor config: not a container directory.
+	if err := os.MkdirAll(filepath.Join(containerdRoot, "containerd"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := Discover(runcRoot, containerdRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewContainer(runcRoot, containerdRoot, "b")
+	b.Execs = []string{filepath.Join(containerdRoot, "b", "e1"+ProcessSuffix), filepath.Join(containerdRoot, "b", "e2"+ProcessSuffix)}
+	expected := []Container{
+		NewContainer(runcRoot, containerdRoot, "a"),
+		b,
+	}
+	if !reflect.DeepEqual(inv.Containers, expected) {
+		t.Fatalf("containers: %#v | %#v", expected, inv.Containers)
+	}
+	if id := ProcessID(b.Execs[0]); id != "e1" {
+		t.Fatalf("exec ID: %s", id)
+	}
+	if id := ProcessID(b.Process); id != "init" {
+		t.Fatalf("init ID: %s", id)
+	}
+
+	for i, d := range [...]struct {
+		id      string
+		missing []string
+	}{
+		{"no-config", []string{ConfigFile, ProcessFile}},
+		{"no-process", []string{ProcessFile}},
+		{"no-state", []string{StateFile}},
+	} {
+		if i >= len(inv.Orphans) {
+			t.Fatalf("orphans: missing %s in %v", d.id, inv.Orphans)
+		}
+		o := inv.Orphans[i]
+		if o.ID != d.id {
+			t.Fatalf("orphan %d: %s | %s", i, d.id, o.ID)
+		}
+		var missing []string
+		for _, path := range o.Missing {
+			missing = append(missing, filepath.Base(path))
+		}
+		if !reflect.DeepEqual(missing, d.missing) {
+			t.Fatalf("orphan %s (missing): %v | %v", d.id, d.missing, missing)
+		}
+	}
+	if len(inv.Orphans) != 3 {
+		t.Fatalf("orphans: %v", inv.Orphans)
+	}
+}
+
+func TestDiscoverMissingRoot(t *testing.T) {
+	inv, err := Discover("/nonexistent/runc", "/nonexistent/libcontainerd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Containers) != 0 || len(inv.Orphans) != 0 {
+		t.Fatalf("expected empty inventory, got %#v", inv)
+	}
+}
@@ -0,0 +1,182 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DecodeError is returned when a state file cannot be decoded, or its version
+// cannot be detected.
+//
+// Upgraders return a *DecodeError with only Err set when they cannot decode a
+// document; Convert fills in the file and the location of the error.
+type DecodeError struct {
+	// File is the path of the state file, empty for documents read from
+	// a stream.
+	File string
+	Kind Kind
+	// JSONPath is the path of the offending value, such as
+	// ".process.args[0]", if known.
+	JSONPath string
+	// Offset is the offset in bytes of the offending value, if known.
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	doc := &Document{Path: e.File, Kind: e.Kind}
+	msg := "error decoding " + doc.name()
+	if e.JSONPath != "" {
+		msg += " at " + e.JSONPath
+	}
+	if e.Offset > 0 {
+		msg += fmt.Sprintf(" (offset %d)", e.Offset)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// decodeError returns err as a *DecodeError about doc, locating the error in
+// the document when possible.
+func decodeError(doc *Document, err error) *DecodeError {
+	var e *DecodeError
+	if !errors.As(err, &e) {
+		e = &DecodeError{Err: err}
+	}
+	e.File, e.Kind = doc.Path, doc.Kind
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		fieldsErr *UnexpectedFieldsError
+	)
+	switch {
+	case errors.As(e.Err, &syntaxErr):
+		e.Offset = syntaxErr.Offset
+	case errors.As(e.Err, &typeErr):
+		e.Offset = typeErr.Offset
+		if e.JSONPath == "" && typeErr.Field != "" {
+			e.JSONPath = "." + typeErr.Field
+		}
+	case errors.As(e.Err, &fieldsErr):
+		if e.JSONPath == "" && len(fieldsErr.Fields) > 0 {
+			e.JSONPath = fieldsErr.Fields[0].String()
+		}
+	}
+	return e
+}
+
+// isDecodeError reports whether err is a failure to decode a document.
+func isDecodeError(err error) bool {
+	var (
+		decodeErr *DecodeError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	return errors.As(err, &decodeErr) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
+
+// ConvertError is returned when an upgrader cannot convert a state file that
+// it decoded, for example because the target version cannot represent it.
+type ConvertError struct {
+	File     string
+	Kind     Kind
+	From, To Version
+	Err      error
+}
+
+func (e *ConvertError) Error() string {
+	doc := &Document{Path: e.File, Kind: e.Kind}
+	return fmt.Sprintf("error upgrading %s from %s to %s: %v", doc.name(), e.From, e.To, e.Err)
+}
+
+func (e *ConvertError) Unwrap() error { return e.Err }
+
+// WriteError is returned when a file cannot be written.
+type WriteError struct {
+	File string
+	// Op is what was being written: "stage", "journal", "apply",
+	// "rollback", "backup" or "marker".
+	Op  string
+	Err error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("error writing %s (%s): %v", e.File, e.Op, e.Err)
+}
+
+func (e *WriteError) Unwrap() error { return e.Err }
+
+// ConsistencyError is returned when state files disagree with each other, or
+// with the records kept by the upgrade: the journal, the backups and the
+// version marker.
+type ConsistencyError struct {
+	Container string
+	Files     []string
+	Reason    string
+}
+
+func (e *ConsistencyError) Error() string {
+	return fmt.Sprintf("inconsistent state files of %s (%s): %s", e.Container, strings.Join(e.Files, ", "), e.Reason)
+}
+
+// InvalidError is returned when a converted file does not follow the
+// specification of its format, and so is not written.
+type InvalidError struct {
+	File       string
+	Kind       Kind
+	Violations []Violation
+}
+
+func (e *InvalidError) Error() string {
+	doc := &Document{Path: e.File, Kind: e.Kind}
+	msg := fmt.Sprintf("invalid %s: %s", doc.name(), e.Violations[0])
+	if len(e.Violations) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Violations)-1)
+	}
+	return msg
+}
+
+// ContainerError is the failure of the upgrade of a single container.
+type ContainerError struct {
+	ID  string
+	Err error
+}
+
+func (e *ContainerError) Error() string {
+	return fmt.Sprintf("%s: %v", e.ID, e.Err)
+}
+
+func (e *ContainerError) Unwrap() error { return e.Err }
+
+// Errors is a list of errors returned together. errors.Is and errors.As
+// look into each of them.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	for _, d := range [...]struct {
+		name   string
+		data   string
+		path   string
+		offset int64
+	}{
+		{"syntax", `{"a": 1,}`, "", 9},
+		{"type", `{"a": "1"}`, ".a", 9},
+	} {
+		var v struct{ A int }
+		err := json.Unmarshal([]byte(d.data), &v)
+		if err == nil {
+			t.Fatalf("%s: expected an error", d.name)
+		}
+		doc := &Document{Path: "/run/a/state.json", Kind: KindState}
+		wrapped := fmt.Errorf("wrapped: %w", decodeError(doc, &DecodeError{Err: err}))
+		var e *DecodeError
+		if !errors.As(wrapped, &e) {
+			t.Fatalf("%s: not a DecodeError: %v", d.name, wrapped)
+		}
+		if e.File != doc.Path || e.Kind != KindState || e.JSONPath != d.path || e.Offset != d.offset {
+			t.Fatalf("%s: %+v", d.name, e)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	fs := NewMemFS()
+	c, docs := newJournalContainer(t, fs, "/")
+	fs.Fail = func(op, name string) error {
+		if op == "write" && name == c.Config+stagedSuffix {
+			return syscall.ENOSPC
+		}
+		return nil
+	}
+	err := commit(fs, c.Journal(), docs)
+	var e *WriteError
+	if !errors.As(err, &e) || e.File != c.Config || e.Op != "stage" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("the cause is lost: %v", err)
+	}
+}
+
+func TestBulkReportErr(t *testing.T) {
+	r := &BulkReport{Results: []BulkResult{
+		{ID: "a"},
+		{ID: "b", Err: &WriteError{File: "/b", Op: "apply", Err: syscall.EIO}},
+	}}
+	err := r.Err()
+	var e *ContainerError
+	if !errors.As(err, &e) || e.ID != "b" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var w *WriteError
+	if !errors.As(err, &w) || !errors.Is(err, syscall.EIO) {
+		t.Fatalf("the cause is lost: %v", err)
+	}
+	if err := (&BulkReport{Results: r.Results[:1]}).Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
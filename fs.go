@@ -0,0 +1,521 @@
+package upgrade
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FS is the file system holding the state files. Paths are absolute.
+type FS interface {
+	Open(name string) (io.ReadCloser, error)
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	MkdirAll(name string, perm os.FileMode) error
+	// WriteFile atomically replaces the contents of name with data, and
+	// flushes them to disk before returning.
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	// SyncDir flushes the entries of the directory name to disk.
+	SyncDir(name string) error
+	Chown(name string, uid, gid int) error
+	// Lock takes an exclusive advisory lock on the file or directory
+	// name, released by closing the returned io.Closer. It fails with
+	// syscall.EWOULDBLOCK instead of waiting if the lock is held.
+	Lock(name string) (io.Closer, error)
+}
+
+// HostFS is the file system of the host.
+var HostFS FS = hostFS{}
+
+type hostFS struct{}
+
+func (hostFS) Open(name string) (io.ReadCloser, error) { return os.Open(name) }
+
+func (hostFS) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+func (hostFS) ReadDir(name string) ([]os.FileInfo, error) { return ioutil.ReadDir(name) }
+
+func (hostFS) MkdirAll(name string, perm os.FileMode) error { return os.MkdirAll(name, perm) }
+
+func (hostFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (hostFS) Rename(oldname, newname string) error { return os.Rename(oldname, newname) }
+
+func (hostFS) Remove(name string) error { return os.Remove(name) }
+
+func (hostFS) RemoveAll(name string) error { return os.RemoveAll(name) }
+
+func (hostFS) SyncDir(name string) error {
+	d, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (hostFS) Chown(name string, uid, gid int) error { return os.Chown(name, uid, gid) }
+
+func (hostFS) Lock(name string) (io.Closer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "flock", Path: name, Err: err}
+	}
+	// closing the file releases the lock.
+	return f, nil
+}
+
+// RootFS returns the file system of the host seen from root, for example the
+// root of another system mounted in a recovery environment. Paths cannot go
+// above root, and the symbolic links they go through are resolved inside
+// root, as if it were the root directory: a link from /var/run to /run leads
+// to the /run of root, not to the one of the host.
+func RootFS(root string) FS {
+	return rootFS{root: root}
+}
+
+type rootFS struct {
+	root string
+}
+
+// maxSymlinks is the number of symbolic links a path can go through, as in
+// Linux.
+const maxSymlinks = 40
+
+// path returns name on the host. If last is not set, the last element of name
+// is not resolved, for the operations on a symbolic link itself.
+func (r rootFS) path(op, name string, last bool) (string, error) {
+	current := "/"
+	// cleaning the path from / removes the leading "..".
+	unresolved := strings.Split(filepath.Clean("/"+name), "/")
+	links := 0
+	for len(unresolved) > 0 {
+		part := unresolved[0]
+		unresolved = unresolved[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+			continue
+		}
+		next := filepath.Join(current, part)
+		if len(unresolved) == 0 && !last {
+			current = next
+			break
+		}
+		target, err := os.Readlink(filepath.Join(r.root, next))
+		if err != nil {
+			// not a symbolic link, or missing.
+			current = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", &os.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+		}
+		if filepath.IsAbs(target) {
+			current = "/"
+		}
+		unresolved = append(strings.Split(target, "/"), unresolved...)
+	}
+	return filepath.Join(r.root, current), nil
+}
+
+func (r rootFS) Open(name string) (io.ReadCloser, error) {
+	p, err := r.path("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return HostFS.Open(p)
+}
+
+func (r rootFS) Stat(name string) (os.FileInfo, error) {
+	p, err := r.path("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return HostFS.Stat(p)
+}
+
+func (r rootFS) ReadDir(name string) ([]os.FileInfo, error) {
+	p, err := r.path("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return HostFS.ReadDir(p)
+}
+
+func (r rootFS) MkdirAll(name string, perm os.FileMode) error {
+	p, err := r.path("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	return HostFS.MkdirAll(p, perm)
+}
+
+func (r rootFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	p, err := r.path("write", name, true)
+	if err != nil {
+		return err
+	}
+	return HostFS.WriteFile(p, data, perm)
+}
+
+func (r rootFS) Rename(oldname, newname string) error {
+	oldpath, err := r.path("rename", oldname, false)
+	if err != nil {
+		return err
+	}
+	newpath, err := r.path("rename", newname, false)
+	if err != nil {
+		return err
+	}
+	return HostFS.Rename(oldpath, newpath)
+}
+
+func (r rootFS) Remove(name string) error {
+	p, err := r.path("remove", name, false)
+	if err != nil {
+		return err
+	}
+	return HostFS.Remove(p)
+}
+
+func (r rootFS) RemoveAll(name string) error {
+	p, err := r.path("remove", name, false)
+	if err != nil {
+		return err
+	}
+	return HostFS.RemoveAll(p)
+}
+
+func (r rootFS) SyncDir(name string) error {
+	p, err := r.path("sync", name, true)
+	if err != nil {
+		return err
+	}
+	return HostFS.SyncDir(p)
+}
+
+func (r rootFS) Chown(name string, uid, gid int) error {
+	p, err := r.path("chown", name, true)
+	if err != nil {
+		return err
+	}
+	return HostFS.Chown(p, uid, gid)
+}
+
+func (r rootFS) Lock(name string) (io.Closer, error) {
+	p, err := r.path("flock", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return HostFS.Lock(p)
+}
+
+// MemFS is a file system held in memory, for tests.
+type MemFS struct {
+	mu     sync.Mutex
+	nodes  map[string]*memNode
+	locked map[string]bool
+	// Fail is called with the name of the operation, such as "write" or
+	// "rename", and the path before every change to the file system. If
+	// it returns an error, the operation fails with it.
+	Fail func(op, name string) error
+}
+
+type memNode struct {
+	data     []byte
+	mode     os.FileMode
+	uid, gid int
+	modTime  time.Time
+}
+
+// NewMemFS returns an empty in-memory file system.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		nodes:  map[string]*memNode{"/": {mode: os.ModeDir | 0755}},
+		locked: make(map[string]bool),
+	}
+}
+
+type memFileInfo struct {
+	name string
+	node *memNode
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return int64(len(fi.node.data)) }
+func (fi memFileInfo) Mode() os.FileMode  { return fi.node.mode }
+func (fi memFileInfo) ModTime() time.Time { return fi.node.modTime }
+func (fi memFileInfo) IsDir() bool        { return fi.node.mode.IsDir() }
+func (fi memFileInfo) Sys() interface{} {
+	return &syscall.Stat_t{Uid: uint32(fi.node.uid), Gid: uint32(fi.node.gid)}
+}
+
+func memPath(name string) string {
+	return filepath.Clean("/" + name)
+}
+
+func (m *MemFS) fail(op, name string) error {
+	if m.Fail == nil {
+		return nil
+	}
+	if err := m.Fail(op, name); err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+// lookup returns the node at name, or an error if it does not exist or if
+// dir is set and it is not a directory.
+func (m *MemFS) lookup(op, name string, dir bool) (*memNode, error) {
+	n, ok := m.nodes[memPath(name)]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: name, Err: syscall.ENOENT}
+	}
+	if dir && !n.mode.IsDir() {
+		return nil, &os.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return n, nil
+}
+
+func (m *MemFS) Open(name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.lookup("open", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(append([]byte(nil), n.data...))), nil
+}
+
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.lookup("stat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return memFileInfo{filepath.Base(memPath(name)), n}, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.lookup("readdir", name, true); err != nil {
+		return nil, err
+	}
+	dir := memPath(name)
+	var entries []os.FileInfo
+	for p, n := range m.nodes {
+		if p != "/" && filepath.Dir(p) == dir {
+			entries = append(entries, memFileInfo{filepath.Base(p), n})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail("mkdir", name); err != nil {
+		return err
+	}
+	p := memPath(name)
+	for ; ; p = filepath.Dir(p) {
+		if n, ok := m.nodes[p]; ok {
+			if !n.mode.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: p, Err: syscall.ENOTDIR}
+			}
+		} else {
+			m.nodes[p] = &memNode{mode: os.ModeDir | perm, modTime: time.Now()}
+		}
+		if p == "/" {
+			return nil
+		}
+	}
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail("write", name); err != nil {
+		return err
+	}
+	if _, err := m.lookup("write", filepath.Dir(memPath(name)), true); err != nil {
+		return err
+	}
+	if n, ok := m.nodes[memPath(name)]; ok && n.mode.IsDir() {
+		return &os.PathError{Op: "write", Path: name, Err: syscall.EISDIR}
+	}
+	m.nodes[memPath(name)] = &memNode{data: append([]byte(nil), data...), mode: perm, modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail("rename", newname); err != nil {
+		return err
+	}
+	n, err := m.lookup("rename", oldname, false)
+	if err != nil {
+		return err
+	}
+	if n.mode.IsDir() {
+		return &os.PathError{Op: "rename", Path: oldname, Err: syscall.EISDIR}
+	}
+	if _, err := m.lookup("rename", filepath.Dir(memPath(newname)), true); err != nil {
+		return err
+	}
+	delete(m.nodes, memPath(oldname))
+	m.nodes[memPath(newname)] = n
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail("remove", name); err != nil {
+		return err
+	}
+	if _, err := m.lookup("remove", name, false); err != nil {
+		return err
+	}
+	p := memPath(name)
+	for other := range m.nodes {
+		if filepath.Dir(other) == p && other != "/" {
+			return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail("removeall", name); err != nil {
+		return err
+	}
+	p := memPath(name)
+	for other := range m.nodes {
+		if other == p || strings.HasPrefix(other, p+"/") {
+			delete(m.nodes, other)
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) SyncDir(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail("syncdir", name); err != nil {
+		return err
+	}
+	_, err := m.lookup("syncdir", name, true)
+	return err
+}
+
+func (m *MemFS) Chown(name string, uid, gid int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail("chown", name); err != nil {
+		return err
+	}
+	n, err := m.lookup("chown", name, false)
+	if err != nil {
+		return err
+	}
+	n.uid, n.gid = uid, gid
+	return nil
+}
+
+func (m *MemFS) Lock(name string) (io.Closer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail("lock", name); err != nil {
+		return nil, err
+	}
+	if _, err := m.lookup("flock", name, false); err != nil {
+		return nil, err
+	}
+	p := memPath(name)
+	if m.locked[p] {
+		return nil, &os.PathError{Op: "flock", Path: name, Err: syscall.EWOULDBLOCK}
+	}
+	m.locked[p] = true
+	return &memLock{fs: m, path: p}, nil
+}
+
+type memLock struct {
+	fs   *MemFS
+	path string
+	once sync.Once
+}
+
+func (l *memLock) Close() error {
+	l.once.Do(func() {
+		l.fs.mu.Lock()
+		delete(l.fs.locked, l.path)
+		l.fs.mu.Unlock()
+	})
+	return nil
+}
+
+// readFile reads the whole file name from fs.
+func readFile(fs FS, name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// owner returns the owner of the file described by fi, if known.
+func owner(fi os.FileInfo) (uid, gid int, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
@@ -0,0 +1,123 @@
+package upgrade
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestRootFS(t *testing.T) {
+	root, err := ioutil.TempDir("", "upgrade-fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	fs := RootFS(filepath.Join(root, "mnt"))
+	if err := fs.MkdirAll("/run", 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/run/a", "../../run/b", "run/../../c"} {
+		if err := fs.WriteFile(name, []byte(name), 0600); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"mnt/run/a", "mnt/run/b", "mnt/c"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+// TestRootFSSymlinks checks that the symbolic links are resolved inside the
+// root.
+func TestRootFSSymlinks(t *testing.T) {
+	root, err := ioutil.TempDir("", "upgrade-fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"run", "mnt/run", "mnt/var"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"mnt/var/run":  "/run",
+		"mnt/var/up":   "../../../..",
+		"mnt/var/loop": "loop",
+		"mnt/run/a":    "/run/b",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := RootFS(filepath.Join(root, "mnt"))
+	for _, name := range []string{"/var/run/b", "/var/up/run/c"} {
+		if err := fs.WriteFile(name, []byte(name), 0600); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"mnt/run/b", "mnt/run/c"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if entries, err := ioutil.ReadDir(filepath.Join(root, "run")); err != nil || len(entries) != 0 {
+		t.Fatalf("the host was written: %v, %v", entries, err)
+	}
+	f, err := fs.Open("/var/run/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil || string(b) != "/var/run/b" {
+		t.Fatalf("/var/run/a: %q, %v", b, err)
+	}
+	// the link itself is removed.
+	if err := fs.Remove("/run/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "mnt/run/b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat("/var/loop"); err == nil || err.(*os.PathError).Err != syscall.ELOOP {
+		t.Fatalf("/var/loop: %v", err)
+	}
+}
+
+func TestDiscoverFS(t *testing.T) {
+	fs := NewMemFS()
+	c := NewContainer("/runc", "/libcontainerd", "a")
+	c.FS = fs
+	for _, name := range c.Files() {
+		if err := fs.MkdirAll(filepath.Dir(name), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.WriteFile(name, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.WriteFile("/runc/b", []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := DiscoverFS(fs, "/runc", "/libcontainerd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Containers) != 1 || !reflect.DeepEqual(inv.Containers[0], c) || len(inv.Orphans) != 0 {
+		t.Fatalf("%#v", inv)
+	}
+	if err := fs.RemoveAll("/runc/a"); err != nil {
+		t.Fatal(err)
+	}
+	if inv, err = DiscoverFS(fs, "/runc", "/libcontainerd"); err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Containers) != 0 || len(inv.Orphans) != 1 {
+		t.Fatalf("%#v", inv)
+	}
+}
@@ -0,0 +1,64 @@
+// +build ignore
+
+// embed-files writes a Go file declaring a map from the base names of files
+// to their contents, so that they are available without the source tree.
+//
+//	go run ../gen/embed-files.go -- OUTPUT VARIABLE FILE...
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) < 3 {
+		fatal(fmt.Errorf("usage: embed-files OUTPUT VARIABLE FILE..."))
+	}
+	output, variable, files := args[0], args[1], args[2:]
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// DO NOT EDIT\n// This file has been auto-generated with go generate.\n\npackage %s\n\n", os.Getenv("GOPACKAGE"))
+	fmt.Fprintf(&buf, "var %s = map[string]string{\n", variable)
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(&buf, "%s: %s,\n", strconv.Quote(filepath.Base(name)), quote(b))
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	content, err := format.Source(buf.Bytes())
+	if err != nil {
+		fatal(err)
+	}
+	if err := ioutil.WriteFile(output, content, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+// quote returns b as a raw string literal if possible, so that the generated
+// file stays readable.
+func quote(b []byte) string {
+	if bytes.IndexByte(b, '`') < 0 && bytes.IndexByte(b, '\r') < 0 {
+		return "`" + string(b) + "`"
+	}
+	return strconv.Quote(string(b))
+}
@@ -184,6 +184,13 @@ func (r *rewriter) writeType(buf *bytes.Buffer, fieldPath string, anonymous bool
 		for i := 0; i < x.NumFields(); i++ {
 			f := x.Field(i)
 			anonymous := f.Anonymous()
+			if rewritten := r.m[fieldPath+"."+f.Name()]; anonymous && rewritten != "" {
+				// embedded structs are unrolled, unless rewritten:
+				// the new type is then embedded instead.
+				buf.WriteString(rewritten)
+				buf.WriteByte(';')
+				continue
+			}
 			if !anonymous {
 				buf.WriteString(f.Name())
 				buf.WriteByte(' ')
@@ -0,0 +1,268 @@
+package upgrade
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Suffixes of the files created next to a state file while it is replaced.
+const (
+	stagedSuffix   = ".upgrade-new"
+	originalSuffix = ".upgrade-orig"
+)
+
+// JournalFile is the name of the intent log written in the libcontainerd
+// directory of a container while its state files are replaced.
+const JournalFile = ".upgrade-journal"
+
+// Journal returns the path of the intent log of c.
+func (c Container) Journal() string {
+	return filepath.Join(filepath.Dir(c.Config), JournalFile)
+}
+
+type journalEntry struct {
+	Path string      `json:"path"`
+	Mode os.FileMode `json:"mode"`
+	// Digest is the sha256 of the new contents of Path.
+	Digest string `json:"digest"`
+}
+
+// journal records the files being replaced by a commit, so that an
+// interrupted commit can be completed or undone by Recover.
+type journal struct {
+	fs      FS
+	path    string
+	Entries []journalEntry `json:"entries"`
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// commit replaces the state files with the contents of docs, all or nothing.
+//
+// The new contents and a copy of the current contents are first written and
+// synced next to each file, then the intent log is written. Only then are the
+// new files renamed in place. If any rename fails, the original files are
+// restored. If the process dies in the middle, Recover completes the commit.
+func commit(fs FS, journalPath string, docs []*Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	j, err := stage(fs, journalPath, docs)
+	if err != nil {
+		j.cleanup()
+		return err
+	}
+	if err := j.write(); err != nil {
+		j.cleanup()
+		return err
+	}
+	if err := j.apply(); err != nil {
+		if errs := j.rollback(); len(errs) > 0 {
+			return append(Errors{err}, errs...)
+		}
+		return err
+	}
+	return j.finish()
+}
+
+// stage writes the new and the original contents of every document next to
+// it, with the same mode and owner.
+func stage(fs FS, journalPath string, docs []*Document) (*journal, error) {
+	j := &journal{fs: fs, path: journalPath}
+	for _, doc := range docs {
+		if err := j.stage(doc); err != nil {
+			return j, &WriteError{File: doc.Path, Op: "stage", Err: err}
+		}
+	}
+	if err := j.syncDirs(); err != nil {
+		return j, &WriteError{File: docs[0].Path, Op: "stage", Err: err}
+	}
+	return j, nil
+}
+
+func (j *journal) stage(doc *Document) error {
+	fs := j.fs
+	fi, err := fs.Stat(doc.Path)
+	if err != nil {
+		return err
+	}
+	original, err := readFile(fs, doc.Path)
+	if err != nil {
+		return err
+	}
+	j.Entries = append(j.Entries, journalEntry{Path: doc.Path, Mode: fi.Mode(), Digest: digest(doc.Data)})
+	for _, f := range []struct {
+		path string
+		data []byte
+	}{
+		{doc.Path + originalSuffix, original},
+		{doc.Path + stagedSuffix, doc.Data},
+	} {
+		if err := fs.WriteFile(f.path, f.data, fi.Mode()); err != nil {
+			return err
+		}
+		if uid, gid, ok := owner(fi); ok {
+			if err := fs.Chown(f.path, uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (j *journal) write() error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	if err := j.fs.WriteFile(j.path, b, 0600); err != nil {
+		return &WriteError{File: j.path, Op: "journal", Err: err}
+	}
+	if err := j.fs.SyncDir(filepath.Dir(j.path)); err != nil {
+		return &WriteError{File: j.path, Op: "journal", Err: err}
+	}
+	return nil
+}
+
+func (j *journal) apply() error {
+	for _, e := range j.Entries {
+		if err := j.fs.Rename(e.Path+stagedSuffix, e.Path); err != nil {
+			return &WriteError{File: e.Path, Op: "apply", Err: err}
+		}
+	}
+	if err := j.syncDirs(); err != nil {
+		return &WriteError{File: j.Entries[0].Path, Op: "apply", Err: err}
+	}
+	return nil
+}
+
+// finish removes the intent log, then the copies of the original files.
+func (j *journal) finish() error {
+	if err := j.fs.Remove(j.path); err != nil {
+		return &WriteError{File: j.path, Op: "journal", Err: err}
+	}
+	if err := j.fs.SyncDir(filepath.Dir(j.path)); err != nil {
+		return &WriteError{File: j.path, Op: "journal", Err: err}
+	}
+	j.cleanup()
+	return nil
+}
+
+// rollback puts the original files back in place and removes the intent log.
+// It returns one error per file that could not be restored.
+func (j *journal) rollback() Errors {
+	var errs Errors
+	for _, e := range j.Entries {
+		if _, err := j.fs.Stat(e.Path + originalSuffix); err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, &WriteError{File: e.Path, Op: "rollback", Err: err})
+			}
+			continue
+		}
+		if err := j.fs.Rename(e.Path+originalSuffix, e.Path); err != nil {
+			errs = append(errs, &WriteError{File: e.Path, Op: "rollback", Err: err})
+		}
+	}
+	if err := j.syncDirs(); err != nil {
+		errs = append(errs, &WriteError{File: j.Entries[0].Path, Op: "rollback", Err: err})
+	}
+	if errs != nil {
+		return errs
+	}
+	if err := j.fs.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return Errors{&WriteError{File: j.path, Op: "journal", Err: err}}
+	}
+	j.cleanup()
+	if err := j.fs.SyncDir(filepath.Dir(j.path)); err != nil {
+		return Errors{&WriteError{File: j.path, Op: "journal", Err: err}}
+	}
+	return nil
+}
+
+// cleanup removes the files left next to the state files, ignoring errors.
+func (j *journal) cleanup() {
+	for _, e := range j.Entries {
+		j.fs.Remove(e.Path + stagedSuffix)
+		j.fs.Remove(e.Path + originalSuffix)
+	}
+}
+
+func (j *journal) syncDirs() error {
+	done := make(map[string]bool)
+	for _, e := range j.Entries {
+		dir := filepath.Dir(e.Path)
+		if done[dir] {
+			continue
+		}
+		done[dir] = true
+		if err := j.fs.SyncDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completed reports whether every file of the journal either has its new
+// contents staged, or already in place.
+func (j *journal) completed() bool {
+	for _, e := range j.Entries {
+		if _, err := j.fs.Stat(e.Path + stagedSuffix); err == nil {
+			continue
+		}
+		b, err := readFile(j.fs, e.Path)
+		if err != nil || digest(b) != e.Digest {
+			return false
+		}
+	}
+	return true
+}
+
+// Recover completes the commit of an upgrade of c that was interrupted, or
+// restores the original files if it cannot be completed. It does nothing if
+// no upgrade was interrupted.
+func Recover(c Container) error {
+	c, err := c.withExecs()
+	if err != nil {
+		return err
+	}
+	fs := c.fs()
+	b, err := readFile(fs, c.Journal())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		// a commit may have died before writing the intent log.
+		j := &journal{fs: fs, path: c.Journal()}
+		for _, path := range c.Files() {
+			j.Entries = append(j.Entries, journalEntry{Path: path})
+		}
+		j.cleanup()
+		return nil
+	}
+	j := &journal{fs: fs, path: c.Journal()}
+	if err := json.Unmarshal(b, j); err != nil {
+		return fmt.Errorf("error decoding %s: %w", c.Journal(), err)
+	}
+	if !j.completed() {
+		if errs := j.rollback(); len(errs) > 0 {
+			return errs
+		}
+		return nil
+	}
+	for _, e := range j.Entries {
+		if err := fs.Rename(e.Path+stagedSuffix, e.Path); err != nil && !os.IsNotExist(err) {
+			return &WriteError{File: e.Path, Op: "apply", Err: err}
+		}
+	}
+	if err := j.syncDirs(); err != nil {
+		return &WriteError{File: j.Entries[0].Path, Op: "apply", Err: err}
+	}
+	return j.finish()
+}
@@ -0,0 +1,177 @@
+package upgrade
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// newJournalContainer creates a container under root on fs whose state files
+// contain their own kind, and returns documents replacing them with
+// "new <kind>".
+func newJournalContainer(t *testing.T, fs FS, root string) (Container, []*Document) {
+	c := NewContainer(filepath.Join(root, "runc"), filepath.Join(root, "libcontainerd"), "a")
+	c.FS = fs
+	var docs []*Document
+	for _, doc := range c.documents() {
+		if err := fs.MkdirAll(filepath.Dir(doc.Path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.WriteFile(doc.Path, []byte(doc.Kind.String()), 0640); err != nil {
+			t.Fatal(err)
+		}
+		doc.Data = []byte("new " + doc.Kind.String())
+		docs = append(docs, doc)
+	}
+	return c, docs
+}
+
+// checkFiles fails unless every state file of c contains prefix followed by
+// its kind, with its mode preserved, and no file is left next to them.
+func checkFiles(t *testing.T, c Container, prefix string) {
+	fs := c.fs()
+	for _, doc := range c.documents() {
+		b, err := readFile(fs, doc.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := prefix + doc.Kind.String(); string(b) != expected {
+			t.Fatalf("%s: %q | %q", doc.Path, expected, b)
+		}
+		fi, err := fs.Stat(doc.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode() != 0640 {
+			t.Fatalf("%s: mode %v", doc.Path, fi.Mode())
+		}
+		for _, name := range []string{doc.Path + stagedSuffix, doc.Path + originalSuffix} {
+			if _, err := fs.Stat(name); !os.IsNotExist(err) {
+				t.Fatalf("%s left behind: %v", name, err)
+			}
+		}
+	}
+	if _, err := fs.Stat(c.Journal()); !os.IsNotExist(err) {
+		t.Fatalf("journal left behind: %v", err)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	root, err := ioutil.TempDir("", "upgrade-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, d := range [...]struct {
+		name string
+		fs   FS
+		root string
+	}{
+		{"host", HostFS, root},
+		{"root", RootFS(root), "/var/run/docker"},
+		{"memory", NewMemFS(), "/"},
+	} {
+		c, docs := newJournalContainer(t, d.fs, d.root)
+		if err := commit(d.fs, c.Journal(), docs); err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		checkFiles(t, c, "new ")
+	}
+	if _, err := os.Stat(filepath.Join(root, "var/run/docker/runc/a", StateFile)); err != nil {
+		t.Fatalf("root: %v", err)
+	}
+}
+
+func TestCommitOwner(t *testing.T) {
+	fs := NewMemFS()
+	c, docs := newJournalContainer(t, fs, "/")
+	if err := fs.Chown(c.Config, 1000, 1001); err != nil {
+		t.Fatal(err)
+	}
+	if err := commit(fs, c.Journal(), docs); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := fs.Stat(c.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid, gid, _ := owner(fi); uid != 1000 || gid != 1001 {
+		t.Fatalf("owner %d:%d", uid, gid)
+	}
+}
+
+func TestCommitRollback(t *testing.T) {
+	for _, d := range [...]struct {
+		name string
+		// the first op on path fails with err.
+		op   string
+		path func(c Container) string
+		err  error
+	}{
+		{"no space left for the second file", "write", func(c Container) string { return c.Config + stagedSuffix }, syscall.ENOSPC},
+		{"no space left for the journal", "write", Container.Journal, syscall.ENOSPC},
+		{"moving the last file in place", "rename", func(c Container) string { return c.Process }, syscall.EIO},
+	} {
+		fs := NewMemFS()
+		c, docs := newJournalContainer(t, fs, "/")
+		failed := false
+		fs.Fail = func(op, name string) error {
+			if failed || op != d.op || name != d.path(c) {
+				return nil
+			}
+			failed = true
+			return d.err
+		}
+		if err := commit(fs, c.Journal(), docs); err == nil {
+			t.Fatalf("%s: expected an error", d.name)
+		}
+		checkFiles(t, c, "")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	for _, d := range [...]struct {
+		name string
+		// applied is the number of files moved in place before the crash.
+		applied int
+		// journal is whether the intent log was written before the crash.
+		journal bool
+		// corrupt overwrites a moved file, so the commit cannot complete.
+		corrupt bool
+		prefix  string
+	}{
+		{"before journal", 0, false, false, ""},
+		{"after journal", 0, true, false, "new "},
+		{"after first rename", 1, true, false, "new "},
+		{"after all renames", 3, true, false, "new "},
+		{"corrupted", 1, true, true, ""},
+	} {
+		fs := NewMemFS()
+		c, docs := newJournalContainer(t, fs, "/")
+		j, err := stage(fs, c.Journal(), docs)
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		if d.journal {
+			if err := j.write(); err != nil {
+				t.Fatalf("%s: %v", d.name, err)
+			}
+		}
+		for _, e := range j.Entries[:d.applied] {
+			if err := fs.Rename(e.Path+stagedSuffix, e.Path); err != nil {
+				t.Fatalf("%s: %v", d.name, err)
+			}
+		}
+		if d.corrupt {
+			if err := fs.WriteFile(c.State, []byte("garbage"), 0640); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := Recover(c); err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		checkFiles(t, c, d.prefix)
+	}
+}
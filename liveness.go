@@ -0,0 +1,176 @@
+package upgrade
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultProcRoot is where procfs is mounted.
+const DefaultProcRoot = "/proc"
+
+// Liveness tells whether the init process of a container is still running.
+type Liveness int
+
+const (
+	// LivenessUnknown means that liveness was not checked.
+	LivenessUnknown Liveness = iota
+	// LivenessLive means that the init process is running.
+	LivenessLive
+	// LivenessDead means that there is no process with the pid of the
+	// init process, or that it is a zombie.
+	LivenessDead
+	// LivenessPIDReused means that the pid of the init process belongs to
+	// another process, started at a different time.
+	LivenessPIDReused
+)
+
+func (l Liveness) String() string {
+	switch l {
+	case LivenessUnknown:
+		return "unknown"
+	case LivenessLive:
+		return "live"
+	case LivenessDead:
+		return "dead"
+	case LivenessPIDReused:
+		return "pid-reused"
+	}
+	return fmt.Sprintf("Liveness(%d)", int(l))
+}
+
+// DeadPolicy is what Upgrade does with a container whose init process is not
+// running anymore.
+type DeadPolicy int
+
+const (
+	// DeadSkip leaves the state files of the container untouched.
+	DeadSkip DeadPolicy = iota
+	// DeadCleanup removes the state files of the container, as the daemon
+	// would when failing to restore it, once they are saved in a new
+	// backup. The backups of the container are kept.
+	DeadCleanup
+)
+
+// ParseDeadPolicy returns the policy named "skip" or "cleanup".
+func ParseDeadPolicy(s string) (DeadPolicy, error) {
+	switch s {
+	case "skip":
+		return DeadSkip, nil
+	case "cleanup":
+		return DeadCleanup, nil
+	}
+	return DeadSkip, fmt.Errorf("unknown policy for dead containers: %s", s)
+}
+
+// CheckLiveness compares the pid and start time of the init process recorded
+// in the runc state of c with the process table under procRoot. procRoot is
+// on the file system of the host, whatever the file system of c.
+func CheckLiveness(procRoot string, c Container) (Liveness, error) {
+	b, err := readFile(c.fs(), c.State)
+	if err != nil {
+		return LivenessUnknown, err
+	}
+	var s struct {
+		Pid   int         `json:"init_process_pid"`
+		Start interface{} `json:"init_process_start"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&s); err != nil {
+		return LivenessUnknown, fmt.Errorf("error decoding %s: %v", c.State, err)
+	}
+	var start string
+	switch v := s.Start.(type) {
+	case string:
+		start = v
+	case json.Number:
+		start = v.String()
+	default:
+		return LivenessUnknown, fmt.Errorf("invalid init_process_start in %s: %v", c.State, s.Start)
+	}
+	if s.Pid <= 0 {
+		return LivenessUnknown, fmt.Errorf("invalid init_process_pid in %s: %d", c.State, s.Pid)
+	}
+	return processLiveness(procRoot, s.Pid, start)
+}
+
+func processLiveness(procRoot string, pid int, start string) (Liveness, error) {
+	b, err := ioutil.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return LivenessDead, nil
+		}
+		return LivenessUnknown, err
+	}
+	state, startTime, err := parseStat(string(b))
+	if err != nil {
+		return LivenessUnknown, fmt.Errorf("error parsing stat of process %d: %v", pid, err)
+	}
+	if startTime != start {
+		return LivenessPIDReused, nil
+	}
+	if state == "Z" || state == "X" {
+		return LivenessDead, nil
+	}
+	return LivenessLive, nil
+}
+
+// parseStat returns the state and the start time of a process from the
+// contents of /proc/<pid>/stat.
+func parseStat(stat string) (state, startTime string, err error) {
+	// the command name is in parentheses and may contain anything, so
+	// skip to the last closing parenthesis.
+	i := strings.LastIndex(stat, ")")
+	if i < 0 {
+		return "", "", fmt.Errorf("missing command name")
+	}
+	// the fields after the command name start with the state, field 3,
+	// up to the start time, field 22.
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 20 {
+		return "", "", fmt.Errorf("expected at least 22 fields, got %d", len(fields)+2)
+	}
+	return fields[0], fields[19], nil
+}
+
+// cleanup saves the state files of c in a new backup, from which Rollback can
+// restore them, then removes them along with the version marker, which no
+// longer holds. The state directories are kept, as they hold the lock files,
+// the backups and the journal. It refuses to touch directories that are not
+// named after c, and returns the generation of the backup.
+func (c Container) cleanup(r Retention) (string, error) {
+	for _, dir := range []string{filepath.Dir(c.State), filepath.Dir(c.Config)} {
+		if c.ID == "" || filepath.Base(dir) != c.ID {
+			return "", fmt.Errorf("refusing to remove the state of %s: not the state directory of container %q", dir, c.ID)
+		}
+	}
+	c, err := c.withExecs()
+	if err != nil {
+		return "", err
+	}
+	b, err := CreateBackup(c)
+	if err != nil {
+		return "", fmt.Errorf("error backing up the state files of %s: %w", c.ID, err)
+	}
+	if _, err := PruneBackups(c, r); err != nil {
+		return b.Generation, fmt.Errorf("error pruning the backups of %s: %w", c.ID, err)
+	}
+	fs := c.fs()
+	for _, path := range append(c.Files(), c.Marker()) {
+		if err := fs.Remove(path); err != nil && !os.IsNotExist(err) {
+			return b.Generation, &WriteError{File: path, Op: "cleanup", Err: err}
+		}
+	}
+	for _, dir := range []string{filepath.Dir(c.State), filepath.Dir(c.Config)} {
+		if err := fs.SyncDir(dir); err != nil {
+			return b.Generation, &WriteError{File: dir, Op: "cleanup", Err: err}
+		}
+	}
+	return b.Generation, nil
+}
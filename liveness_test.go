@@ -0,0 +1,120 @@
+package upgrade
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeStat creates a fake /proc/<pid>/stat under procRoot.
+func writeStat(t *testing.T, procRoot string, pid int, state, start string) {
+	fields := []string{state}
+	for i := 4; i < 22; i++ {
+		fields = append(fields, "0")
+	}
+	fields = append(fields, start, "0", "0")
+	stat := fmt.Sprintf("%d (sh ) (x) %s\n", pid, strings.Join(fields, " "))
+	dir := filepath.Join(procRoot, fmt.Sprint(pid))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckLiveness(t *testing.T) {
+	root, err := ioutil.TempDir("", "upgrade-liveness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	procRoot := filepath.Join(root, "proc")
+	writeStat(t, procRoot, 10, "S", "8468990")
+	writeStat(t, procRoot, 11, "S", "1")
+	writeStat(t, procRoot, 12, "Z", "8468990")
+
+	for _, d := range [...]struct {
+		state    string
+		liveness Liveness
+	}{
+		{`{"init_process_pid":10,"init_process_start":"8468990"}`, LivenessLive},
+		{`{"init_process_pid":10,"init_process_start":8468990}`, LivenessLive},
+		{`{"init_process_pid":11,"init_process_start":"8468990"}`, LivenessPIDReused},
+		{`{"init_process_pid":12,"init_process_start":"8468990"}`, LivenessDead},
+		{`{"init_process_pid":13,"init_process_start":"8468990"}`, LivenessDead},
+	} {
+		c := NewContainer(filepath.Join(root, "runc"), filepath.Join(root, "libcontainerd"), "a")
+		touch(t, c.State)
+		if err := ioutil.WriteFile(c.State, []byte(d.state), 0600); err != nil {
+			t.Fatal(err)
+		}
+		l, err := CheckLiveness(procRoot, c)
+		if err != nil {
+			t.Fatalf("%s: %v", d.state, err)
+		}
+		if l != d.liveness {
+			t.Fatalf("%s: %v | %v", d.state, d.liveness, l)
+		}
+	}
+}
+
+func TestUpgradeDead(t *testing.T) {
+	root, err := ioutil.TempDir("", "upgrade-liveness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	c := NewContainer(filepath.Join(root, "runc"), filepath.Join(root, "libcontainerd"), "a")
+	for _, name := range c.Files() {
+		touch(t, name)
+	}
+	if err := ioutil.WriteFile(c.State, []byte(`{"init_process_pid":10,"init_process_start":"1"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Target: V17_06_1, ProcRoot: filepath.Join(root, "proc")}
+	report, err := Upgrade(c, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Liveness != LivenessDead || report.Removed || len(report.Files) != 0 {
+		t.Fatalf("skip: %+v", report)
+	}
+	for _, name := range c.Files() {
+		if _, err := os.Stat(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts.Dead = DeadCleanup
+	if report, err = Upgrade(c, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !report.Removed {
+		t.Fatalf("cleanup: %+v", report)
+	}
+	for _, name := range c.Files() {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s not removed: %v", name, err)
+		}
+	}
+	backups, err := ListBackups(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || backups[0].Generation != report.Backup {
+		t.Fatalf("backups: %+v | %s", backups, report.Backup)
+	}
+	if _, err := Rollback(c, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(c.State)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"init_process_pid":10,"init_process_start":"1"}` {
+		t.Fatalf("state not restored: %s", b)
+	}
+}
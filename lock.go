@@ -0,0 +1,119 @@
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrLocked is returned, wrapped, by Lock when the state directories of a
+// container are still locked by another upgrade after the timeout.
+var ErrLocked = errors.New("state directory locked by another upgrade")
+
+// lockRetryInterval is how often a held lock is tried again.
+const lockRetryInterval = 50 * time.Millisecond
+
+// Lock takes an advisory lock on the runc and the libcontainerd state
+// directories of c, waiting up to timeout for another holder to release them.
+// The returned function releases the locks.
+//
+// runc and containerd 0.2 do not lock their state directories, so the locks
+// only exclude other upgrades of c; FindWriters detects the processes of the
+// runtimes instead.
+func Lock(c Container, timeout time.Duration) (unlock func(), err error) {
+	fs := c.fs()
+	var locks []io.Closer
+	unlock = func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Close()
+		}
+	}
+	deadline := time.Now().Add(timeout)
+	// always lock in the same order to prevent deadlocks.
+	for _, dir := range []string{filepath.Dir(c.State), filepath.Dir(c.Config)} {
+		for {
+			l, err := fs.Lock(dir)
+			if err == nil {
+				locks = append(locks, l)
+				break
+			}
+			if pe, ok := err.(*os.PathError); !ok || pe.Err != syscall.EWOULDBLOCK {
+				unlock()
+				return nil, err
+			}
+			if !time.Now().Before(deadline) {
+				unlock()
+				return nil, fmt.Errorf("timed out after %v waiting for the lock on %s: %w", timeout, dir, ErrLocked)
+			}
+			time.Sleep(lockRetryInterval)
+		}
+	}
+	return unlock, nil
+}
+
+// Writer is a process of the runtime that may write the state files of a
+// container.
+type Writer struct {
+	PID     int
+	Command string
+}
+
+func (w Writer) String() string {
+	return fmt.Sprintf("%s (pid %d)", w.Command, w.PID)
+}
+
+// writerCommands are the names of the executables that write the state files
+// of containers.
+var writerCommands = map[string]bool{
+	"containerd-shim":        true,
+	"docker-containerd-shim": true,
+	"runc":                   true,
+	"docker-runc":            true,
+}
+
+// FindWriters returns the containerd-shim and runc processes found under
+// procRoot that were given the ID of c on their command line.
+//
+// A shim keeps running with the containers restored by the daemon, but it
+// only writes the runc state when the container changes state, for example
+// when it exits.
+func FindWriters(procRoot string, c Container) ([]Writer, error) {
+	entries, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var writers []Writer
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(procRoot, e.Name(), "cmdline"))
+		if err != nil {
+			// the process exited in the meantime.
+			continue
+		}
+		args := strings.Split(strings.TrimRight(string(b), "\x00"), "\x00")
+		command := filepath.Base(args[0])
+		if !writerCommands[command] {
+			continue
+		}
+		for _, arg := range args[1:] {
+			if arg == c.ID {
+				writers = append(writers, Writer{PID: pid, Command: command})
+				break
+			}
+		}
+	}
+	return writers, nil
+}
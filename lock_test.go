@@ -0,0 +1,89 @@
+package upgrade
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	root, err := ioutil.TempDir("", "upgrade-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, d := range [...]struct {
+		name string
+		fs   FS
+		root string
+	}{
+		{"host", HostFS, root},
+		{"memory", NewMemFS(), "/"},
+	} {
+		c, _ := newJournalContainer(t, d.fs, d.root)
+		unlock, err := Lock(c, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		if _, err := Lock(c, 0); err == nil {
+			t.Fatalf("%s: locked twice", d.name)
+		}
+		if _, err := Upgrade(c, Options{Target: V17_06_1, IgnoreLiveness: true}); err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Fatalf("%s: upgrade of a locked container: %v", d.name, err)
+		}
+		first := unlock
+		go func() {
+			time.Sleep(2 * lockRetryInterval)
+			first()
+		}()
+		unlock, err = Lock(c, time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		unlock()
+	}
+}
+
+// writeCmdline creates a fake /proc/<pid>/cmdline under procRoot.
+func writeCmdline(t *testing.T, procRoot string, pid int, args ...string) {
+	dir := filepath.Join(procRoot, fmt.Sprint(pid))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "cmdline"), []byte(strings.Join(args, "\x00")+"\x00"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindWriters(t *testing.T) {
+	procRoot, err := ioutil.TempDir("", "upgrade-proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(procRoot)
+	writeCmdline(t, procRoot, 10, "docker-containerd-shim", "a", "/var/run/docker/libcontainerd/a", "docker-runc")
+	writeCmdline(t, procRoot, 11, "docker-containerd-shim", "b", "/var/run/docker/libcontainerd/b", "docker-runc")
+	writeCmdline(t, procRoot, 12, "/usr/bin/docker-runc", "--root", "/run/docker/runtime-runc/moby", "exec", "a")
+	writeCmdline(t, procRoot, 13, "sleep", "a")
+	writeStat(t, procRoot, 14, "S", "1")
+
+	c, _ := newJournalContainer(t, NewMemFS(), "/")
+	writers, err := FindWriters(procRoot, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Writer{{10, "docker-containerd-shim"}, {12, "docker-runc"}}
+	if !reflect.DeepEqual(writers, expected) {
+		t.Fatalf("%v | %v", expected, writers)
+	}
+
+	opts := Options{Target: V17_06_1, ProcRoot: procRoot, IgnoreLiveness: true, RefuseWriters: true}
+	if _, err := Upgrade(c, opts); err == nil {
+		t.Fatal("expected an error")
+	}
+}
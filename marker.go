@@ -0,0 +1,102 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MarkerFile is the name of the file recording the version that the state
+// files of a container were upgraded to, in its libcontainerd directory.
+const MarkerFile = ".upgrade-version"
+
+// Marker returns the path of the version marker of c.
+func (c Container) Marker() string {
+	return filepath.Join(filepath.Dir(c.Config), MarkerFile)
+}
+
+// marker records the version of the state files of a container after an
+// upgrade. It only holds for the files whose contents still match their
+// digest.
+type marker struct {
+	Version Version       `json:"version"`
+	Files   []markerEntry `json:"files"`
+}
+
+type markerEntry struct {
+	Path string `json:"path"`
+	// Source is the sha256 of the file before the upgrade.
+	Source string `json:"source"`
+	// Digest is the sha256 of the file after the upgrade.
+	Digest string `json:"digest"`
+}
+
+// readMarker returns the marker of c, or nil if there is none.
+func readMarker(c Container) (*marker, error) {
+	b, err := readFile(c.fs(), c.Marker())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	m := &marker{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %v", c.Marker(), err)
+	}
+	return m, nil
+}
+
+// version returns the version recorded for the file path with contents data,
+// or the empty version if the marker does not hold for it.
+func (m *marker) version(path string, data []byte) Version {
+	if m == nil {
+		return ""
+	}
+	for _, e := range m.Files {
+		if e.Path == path && e.Digest == digest(data) {
+			return m.Version
+		}
+	}
+	return ""
+}
+
+// current reports whether every state file of c is recorded at the target
+// version, with unchanged contents.
+func (m *marker) current(c Container, target Version) (bool, error) {
+	if m == nil || m.Version != target {
+		return false, nil
+	}
+	for _, path := range c.Files() {
+		b, err := readFile(c.fs(), path)
+		if err != nil {
+			return false, err
+		}
+		if m.version(path, b) != target {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// writeMarker records that the documents of c are at version, sources holding
+// the digests of their contents before the upgrade.
+func writeMarker(c Container, version Version, docs []*Document, sources map[string]string) error {
+	m := &marker{Version: version}
+	for _, doc := range docs {
+		m.Files = append(m.Files, markerEntry{Path: doc.Path, Source: sources[doc.Path], Digest: digest(doc.Data)})
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	fs := c.fs()
+	if err := fs.WriteFile(c.Marker(), b, 0600); err != nil {
+		return &WriteError{File: c.Marker(), Op: "marker", Err: err}
+	}
+	if err := fs.SyncDir(filepath.Dir(c.Marker())); err != nil {
+		return &WriteError{File: c.Marker(), Op: "marker", Err: err}
+	}
+	return nil
+}
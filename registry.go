@@ -0,0 +1,130 @@
+package upgrade
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// An Upgrader converts state files written by one Docker version to the
+// format of another, which may be an earlier one. Upgraders are provided by
+// the versioned packages, such as v17_06_1, which register them in their init
+// function.
+type Upgrader interface {
+	// From is the version of the documents accepted by Upgrade.
+	From() Version
+	// To is the version of the documents produced by Upgrade.
+	To() Version
+	// Upgrade replaces doc.Data with its conversion. It sets doc.Unknown
+	// to the fields of doc.Data that it does not understand, and
+	// doc.Losses to the information that it could not convert.
+	Upgrade(doc *Document) error
+}
+
+// An OptionsUpgrader is an Upgrader whose conversion depends on the options of
+// the upgrade, such as Options.Capabilities.
+type OptionsUpgrader interface {
+	Upgrader
+	// UpgradeOptions is like Upgrade, but follows opts. Convert calls it
+	// instead of Upgrade.
+	UpgradeOptions(doc *Document, opts Options) error
+}
+
+// A Normalizer is an Upgrader that can also rewrite the documents it produces
+// in a canonical form, without changing their meaning; see
+// Options.Normalize.
+type Normalizer interface {
+	Upgrader
+	// Normalize replaces doc.Data, at To, with its canonical form.
+	Normalize(doc *Document) error
+}
+
+type registry struct {
+	mu         sync.RWMutex
+	upgraders  []Upgrader
+	validators map[Version]Validator
+}
+
+var defaultRegistry = &registry{}
+
+// Register makes u available to Plan. It panics if an upgrader between the
+// same versions is already registered.
+func Register(u Upgrader) {
+	defaultRegistry.register(u)
+}
+
+// Plan returns the shortest sequence of registered upgraders converting
+// documents from one version to another.
+func Plan(from, to Version) ([]Upgrader, error) {
+	return defaultRegistry.plan(from, to)
+}
+
+// Targets returns the versions that documents can be upgraded to, oldest
+// first.
+func Targets() []Version {
+	return defaultRegistry.targets()
+}
+
+func (r *registry) register(u Upgrader) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u == nil {
+		panic("upgrade: Register upgrader is nil")
+	}
+	for _, v := range r.upgraders {
+		if v.From() == u.From() && v.To() == u.To() {
+			panic(fmt.Sprintf("upgrade: Register called twice for %s -> %s", u.From(), u.To()))
+		}
+	}
+	r.upgraders = append(r.upgraders, u)
+}
+
+// plan does a breadth-first search of the upgraders, in registration order.
+func (r *registry) plan(from, to Version) ([]Upgrader, error) {
+	if from == to {
+		return nil, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	prev := map[Version]Upgrader{}
+	visited := map[Version]bool{from: true}
+	queue := []Version{from}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, u := range r.upgraders {
+			if u.From() != v || visited[u.To()] {
+				continue
+			}
+			visited[u.To()] = true
+			prev[u.To()] = u
+			if u.To() != to {
+				queue = append(queue, u.To())
+				continue
+			}
+			var steps []Upgrader
+			for v := to; v != from; v = prev[v].From() {
+				steps = append([]Upgrader{prev[v]}, steps...)
+			}
+			return steps, nil
+		}
+	}
+	return nil, fmt.Errorf("no upgrade path from %s to %s", from, to)
+}
+
+func (r *registry) targets() []Version {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := map[Version]bool{}
+	var targets []Version
+	for _, u := range r.upgraders {
+		if !seen[u.To()] {
+			seen[u.To()] = true
+			targets = append(targets, u.To())
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return compare(targets[i], targets[j]) < 0
+	})
+	return targets
+}
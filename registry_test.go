@@ -0,0 +1,71 @@
+package upgrade
+
+import (
+	"reflect"
+	"testing"
+)
+
+type fakeUpgrader struct {
+	from, to Version
+}
+
+func (u fakeUpgrader) From() Version { return u.from }
+
+func (u fakeUpgrader) To() Version { return u.to }
+
+func (u fakeUpgrader) Upgrade(doc *Document) error {
+	doc.Data = append(doc.Data, []byte(" -> "+u.to)...)
+	return nil
+}
+
+func TestPlan(t *testing.T) {
+	r := &registry{}
+	for _, u := range []fakeUpgrader{
+		{"1", "2"},
+		{"2", "3"},
+		{"3", "4"},
+		{"1", "3"},
+		{"5", "1"},
+	} {
+		r.register(u)
+	}
+	for _, d := range [...]struct {
+		from, to Version
+		path     []Version
+	}{
+		{"1", "1", nil},
+		{"1", "2", []Version{"2"}},
+		{"1", "4", []Version{"3", "4"}},
+		{"2", "4", []Version{"3", "4"}},
+		{"5", "4", []Version{"1", "3", "4"}},
+	} {
+		steps, err := r.plan(d.from, d.to)
+		if err != nil {
+			t.Fatalf("plan %s -> %s: %v", d.from, d.to, err)
+		}
+		var path []Version
+		for _, u := range steps {
+			path = append(path, u.To())
+		}
+		if !reflect.DeepEqual(path, d.path) {
+			t.Fatalf("plan %s -> %s: %v | %v", d.from, d.to, d.path, path)
+		}
+	}
+	if _, err := r.plan("4", "1"); err == nil {
+		t.Fatal("expected no path from 4 to 1")
+	}
+	if targets := r.targets(); !reflect.DeepEqual(targets, []Version{"1", "2", "3", "4"}) {
+		t.Fatalf("targets: %v", targets)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := &registry{}
+	r.register(fakeUpgrader{"1", "2"})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	r.register(fakeUpgrader{"1", "2"})
+}
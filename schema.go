@@ -0,0 +1,387 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A Violation is a part of a document that does not follow the specification
+// of its format.
+type Violation struct {
+	Field  Field
+	Reason string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Field, v.Reason)
+}
+
+// Schema is a JSON Schema, draft 4, along with the schemas it references.
+// Only the keywords used by the schemas of the OCI runtime spec are
+// supported: the others are ignored.
+type Schema struct {
+	// IgnoreNull treats the fields of objects set to null as absent, the
+	// way Go decoders do, instead of checking null against their schema.
+	IgnoreNull bool
+	files      map[string]interface{}
+	root       string
+}
+
+// CompileSchema decodes the schema files, keyed by their name, and returns
+// the schema of the file root. References to other files, such as
+// "defs.json#/definitions/Env", are resolved by name against files.
+func CompileSchema(files map[string]string, root string) (*Schema, error) {
+	s := &Schema{files: make(map[string]interface{}), root: root}
+	for name, data := range files {
+		v, err := decodeJSON([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding schema %s: %w", name, err)
+		}
+		s.files[name] = v
+	}
+	if _, ok := s.files[root]; !ok {
+		return nil, fmt.Errorf("schema %s not found", root)
+	}
+	return s, nil
+}
+
+// Validate returns the violations of the schema by data.
+func (s *Schema) Validate(data []byte) ([]Violation, error) {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	c := &schemaCheck{schema: s}
+	if err := c.check(nil, v, s.files[s.root], s.root); err != nil {
+		return nil, err
+	}
+	return c.violations, nil
+}
+
+type schemaCheck struct {
+	schema     *Schema
+	violations []Violation
+}
+
+func (c *schemaCheck) fail(path Field, format string, args ...interface{}) {
+	c.violations = append(c.violations, Violation{Field: path, Reason: fmt.Sprintf(format, args...)})
+}
+
+// resolve returns the schema referenced by ref from the file named file, and
+// the file it is in.
+func (c *schemaCheck) resolve(ref, file string) (interface{}, string, error) {
+	parts := strings.SplitN(ref, "#", 2)
+	if parts[0] != "" {
+		file = parts[0]
+	}
+	v, ok := c.schema.files[file]
+	if !ok {
+		return nil, "", fmt.Errorf("schema %s not found", file)
+	}
+	if len(parts) == 1 || parts[1] == "" {
+		return v, file, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(parts[1], "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("invalid reference %s in %s", ref, file)
+		}
+		if v, ok = m[token]; !ok {
+			return nil, "", fmt.Errorf("invalid reference %s in %s", ref, file)
+		}
+	}
+	return v, file, nil
+}
+
+// check appends the violations of schema, from file, by v found at path.
+func (c *schemaCheck) check(path Field, v, schema interface{}, file string) error {
+	sch, ok := schema.(map[string]interface{})
+	if !ok {
+		// true or an empty schema.
+		return nil
+	}
+	if ref, ok := sch["$ref"].(string); ok {
+		// draft 4 ignores the siblings of $ref.
+		target, file, err := c.resolve(ref, file)
+		if err != nil {
+			return err
+		}
+		return c.check(path, v, target, file)
+	}
+
+	if t, ok := sch["type"]; ok && !hasType(v, t) {
+		c.fail(path, "expected %s, got %s", typeNames(t), schemaType(v))
+		return nil
+	}
+	if enum, ok := sch["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.fail(path, "%s is not one of %s", jsonString(v), jsonString(enum))
+		}
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		schemas, ok := sch[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		valid := 0
+		for _, s := range schemas {
+			sub := &schemaCheck{schema: c.schema}
+			if err := sub.check(path, v, s, file); err != nil {
+				return err
+			}
+			if len(sub.violations) == 0 {
+				valid++
+			} else if keyword == "allOf" || len(schemas) == 1 {
+				// the violations of the only choice say more than
+				// the failure of the choice.
+				c.violations = append(c.violations, sub.violations...)
+			}
+		}
+		if len(schemas) == 1 {
+			continue
+		}
+		switch {
+		case keyword == "anyOf" && valid == 0:
+			c.fail(path, "does not match any of the allowed schemas")
+		case keyword == "oneOf" && valid != 1:
+			c.fail(path, "matches %d of the allowed schemas instead of one", valid)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return c.checkObject(path, v, sch, file)
+	case []interface{}:
+		return c.checkArray(path, v, sch, file)
+	case string:
+		if n, ok := number(sch["minLength"]); ok && big.NewRat(int64(len([]rune(v))), 1).Cmp(n) < 0 {
+			c.fail(path, "shorter than %s characters", n.RatString())
+		}
+		if n, ok := number(sch["maxLength"]); ok && big.NewRat(int64(len([]rune(v))), 1).Cmp(n) > 0 {
+			c.fail(path, "longer than %s characters", n.RatString())
+		}
+		if pattern, ok := sch["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %s in %s: %w", pattern, file, err)
+			}
+			if !re.MatchString(v) {
+				c.fail(path, "%q does not match %s", v, pattern)
+			}
+		}
+	case json.Number:
+		n, _ := number(v)
+		if min, ok := number(sch["minimum"]); ok {
+			if exclusive, _ := sch["exclusiveMinimum"].(bool); n.Cmp(min) < 0 || exclusive && n.Cmp(min) == 0 {
+				c.fail(path, "%s is less than the minimum %s", v, min.RatString())
+			}
+		}
+		if max, ok := number(sch["maximum"]); ok {
+			if exclusive, _ := sch["exclusiveMaximum"].(bool); n.Cmp(max) > 0 || exclusive && n.Cmp(max) == 0 {
+				c.fail(path, "%s is more than the maximum %s", v, max.RatString())
+			}
+		}
+	}
+	return nil
+}
+
+func (c *schemaCheck) checkObject(path Field, v map[string]interface{}, sch map[string]interface{}, file string) error {
+	if required, ok := sch["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if value, ok := v[name]; !ok || value == nil && c.schema.IgnoreNull {
+					c.fail(path, "missing required field %s", name)
+				}
+			}
+		}
+	}
+	properties, _ := sch["properties"].(map[string]interface{})
+	patterns, _ := sch["patternProperties"].(map[string]interface{})
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v[name] == nil && c.schema.IgnoreNull {
+			continue
+		}
+		matched := false
+		if s, ok := properties[name]; ok {
+			matched = true
+			if err := c.check(path.child(name), v[name], s, file); err != nil {
+				return err
+			}
+		}
+		for pattern, s := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %s in %s: %w", pattern, file, err)
+			}
+			if re.MatchString(name) {
+				matched = true
+				if err := c.check(path.child(name), v[name], s, file); err != nil {
+					return err
+				}
+			}
+		}
+		if matched {
+			continue
+		}
+		switch additional := sch["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				c.fail(path.child(name), "unexpected field")
+			}
+		case map[string]interface{}:
+			if err := c.check(path.child(name), v[name], additional, file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *schemaCheck) checkArray(path Field, v []interface{}, sch map[string]interface{}, file string) error {
+	if n, ok := number(sch["minItems"]); ok && big.NewRat(int64(len(v)), 1).Cmp(n) < 0 {
+		c.fail(path, "fewer than %s items", n.RatString())
+	}
+	if n, ok := number(sch["maxItems"]); ok && big.NewRat(int64(len(v)), 1).Cmp(n) > 0 {
+		c.fail(path, "more than %s items", n.RatString())
+	}
+	if unique, _ := sch["uniqueItems"].(bool); unique {
+		for i := range v {
+			for j := 0; j < i; j++ {
+				if jsonEqual(v[i], v[j]) {
+					c.fail(path.child(i), "duplicate of item %d", j)
+					break
+				}
+			}
+		}
+	}
+	switch items := sch["items"].(type) {
+	case map[string]interface{}:
+		for i, item := range v {
+			if err := c.check(path.child(i), item, items, file); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if i >= len(items) {
+				break
+			}
+			if err := c.check(path.child(i), item, items[i], file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// number returns the exact value of a JSON number.
+func number(v interface{}) (*big.Rat, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetString(n.String())
+}
+
+// schemaType returns the JSON Schema type of v.
+func schemaType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if n, ok := number(v); ok && n.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// hasType reports whether v has the type t, a type name or a list of them.
+func hasType(v interface{}, t interface{}) bool {
+	actual := schemaType(v)
+	for _, name := range typeList(t) {
+		if name == actual || name == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func typeList(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var names []string
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func typeNames(t interface{}) string {
+	return strings.Join(typeList(t), " or ")
+}
+
+// jsonEqual compares decoded JSON values, numbers by value.
+func jsonEqual(a, b interface{}) bool {
+	if a, ok := number(a); ok {
+		b, ok := number(b)
+		return ok && a.Cmp(b) == 0
+	}
+	switch a := a.(type) {
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k := range a {
+			if bk, ok := b[k]; !ok || !jsonEqual(a[k], bk) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
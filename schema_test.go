@@ -0,0 +1,71 @@
+package upgrade
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	s, err := CompileSchema(map[string]string{
+		"root.json": `{
+			"type": "object",
+			"required": ["name"],
+			"additionalProperties": false,
+			"properties": {
+				"name": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+				"weight": {"$ref": "defs.json#/definitions/Weight"},
+				"tags": {"type": "array", "uniqueItems": true, "maxItems": 2, "items": {"enum": ["a", "b", "c"]}},
+				"env": {"type": "object", "patternProperties": {"^[A-Z]+$": {"type": "string"}}},
+				"mode": {"oneOf": [{"type": "integer"}, {"type": "string"}]},
+				"size": {"anyOf": [{"type": "integer"}]}
+			}
+		}`,
+		"defs.json": `{
+			"definitions": {
+				"Weight": {"type": "integer", "anyOf": [{"enum": [0]}, {"minimum": 10, "maximum": 1000}]}
+			}
+		}`,
+	}, "root.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range [...]struct {
+		data       string
+		ignoreNull bool
+		violations []string
+	}{
+		{`{"name": "a", "weight": 0, "tags": ["a", "b"], "env": {"PATH": "/bin"}, "mode": 1}`, false, nil},
+		{`{"name": "a", "weight": 1000}`, false, nil},
+		{`{}`, false, []string{".: missing required field name"}},
+		{`[]`, false, []string{".: expected object, got array"}},
+		{`{"name": ""}`, false, []string{`.name: shorter than 1 characters`, `.name: "" does not match ^[a-z]+$`}},
+		{`{"name": "a", "other": 1}`, false, []string{".other: unexpected field"}},
+		{`{"name": "a", "weight": 5}`, false, []string{".weight: does not match any of the allowed schemas"}},
+		{`{"name": "a", "weight": 1.5}`, false, []string{".weight: expected integer, got number"}},
+		{`{"name": "a", "tags": ["a", "a", "d"]}`, false, []string{".tags: more than 2 items", ".tags[1]: duplicate of item 0", `.tags[2]: "d" is not one of ["a","b","c"]`}},
+		{`{"name": "a", "env": {"PATH": 1, "lower": "x"}}`, false, []string{".env.PATH: expected string, got integer"}},
+		{`{"name": "a", "mode": true}`, false, []string{".mode: matches 0 of the allowed schemas instead of one"}},
+		{`{"name": "a", "size": "1"}`, false, []string{".size: expected integer, got string"}},
+		{`{"name": "a", "weight": null}`, false, []string{".weight: expected integer, got null"}},
+		{`{"name": "a", "weight": null}`, true, nil},
+		{`{"name": null}`, true, []string{".: missing required field name"}},
+	} {
+		s.IgnoreNull = d.ignoreNull
+		violations, err := s.Validate([]byte(d.data))
+		if err != nil {
+			t.Fatalf("%s: %v", d.data, err)
+		}
+		var actual []string
+		for _, v := range violations {
+			actual = append(actual, v.String())
+		}
+		if !reflect.DeepEqual(actual, d.violations) {
+			t.Fatalf("%s: expected %q, got %q", d.data, d.violations, actual)
+		}
+	}
+
+	if _, err := CompileSchema(map[string]string{"a.json": `{}`}, "b.json"); err == nil {
+		t.Fatal("expected an error for a missing root")
+	}
+}
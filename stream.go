@@ -0,0 +1,99 @@
+package upgrade
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// name identifies doc in errors.
+func (doc *Document) name() string {
+	if doc.Path != "" {
+		return doc.Path
+	}
+	return "the " + doc.Kind.String() + " document"
+}
+
+func readDocument(doc *Document, r io.Reader) error {
+	var err error
+	if doc.Data, err = ioutil.ReadAll(r); err != nil {
+		return err
+	}
+	var evidence []Evidence
+	doc.Version, _, evidence = DetectVersion(doc.Kind, doc.Data)
+	if doc.Version == Unknown {
+		if _, err := decodeJSON(doc.Data); err != nil {
+			return decodeError(doc, err)
+		}
+		return decodeError(doc, fmt.Errorf("cannot tell which Docker version wrote it: %v", evidence))
+	}
+	return nil
+}
+
+// ReadDocument reads a state file of the given kind from r, and detects the
+// version that wrote it.
+func ReadDocument(kind Kind, r io.Reader) (*Document, error) {
+	doc := &Document{Kind: kind}
+	if err := readDocument(doc, r); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// upgradeDocument converts doc to opts.Target, and reports the changes. It
+// returns nil if doc is left unchanged.
+func upgradeDocument(doc *Document, opts Options) (*FileReport, error) {
+	if doc.Version == opts.Target {
+		return nil, nil
+	}
+	original := doc.Data
+	file, err := Convert(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if file.Diff, err = DiffJSON(original, doc.Data); err != nil {
+		return nil, fmt.Errorf("error comparing %s: %w", doc.name(), err)
+	}
+	if len(file.Diff) == 0 && len(file.Losses) == 0 {
+		// converted between versions with the same format, or already
+		// in the format of the target.
+		doc.Data = original
+		return nil, nil
+	}
+	if file.Violations, err = ValidateDocument(doc, opts.Target); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// UpgradeStream reads a state file of the given kind from r, converts it to
+// opts.Target and writes the result to w. A file that does not need to be
+// converted is copied as is. Nothing is written if opts.DryRun is set, or if
+// the converted file is invalid and opts.AllowInvalid is not set: the report
+// is then returned along with an *InvalidError.
+//
+// The returned report is nil if the file is unchanged. The options that
+// apply to containers rather than to files, such as the liveness check, are
+// ignored.
+func UpgradeStream(kind Kind, r io.Reader, w io.Writer, opts Options) (*FileReport, error) {
+	doc, err := ReadDocument(kind, r)
+	if err != nil {
+		return nil, err
+	}
+	report, err := upgradeDocument(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if report != nil {
+		if err := invalid([]FileReport{*report}, opts); err != nil {
+			return report, err
+		}
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+	if _, err := w.Write(doc.Data); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
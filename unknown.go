@@ -0,0 +1,286 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Field is the location of a value in a JSON document, as a list of object
+// keys (strings) and array indexes (ints).
+type Field []interface{}
+
+// String returns f as a path such as ".linux.seccomp.syscalls[3].names".
+func (f Field) String() string {
+	if len(f) == 0 {
+		return "."
+	}
+	var s string
+	for _, p := range f {
+		switch p := p.(type) {
+		case string:
+			s += "." + p
+		case int:
+			s += "[" + strconv.Itoa(p) + "]"
+		}
+	}
+	return s
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Pointer returns f as an RFC 6901 JSON Pointer.
+func (f Field) Pointer() string {
+	var s string
+	for _, p := range f {
+		switch p := p.(type) {
+		case string:
+			s += "/" + pointerEscaper.Replace(p)
+		case int:
+			s += "/" + strconv.Itoa(p)
+		}
+	}
+	return s
+}
+
+// child returns a copy of f extended with p.
+func (f Field) child(p interface{}) Field {
+	return append(f[:len(f):len(f)], p)
+}
+
+// UnexpectedFieldsError is returned by strict decoding when a document has
+// fields that the type it is decoded into does not define.
+type UnexpectedFieldsError struct {
+	Fields []Field
+}
+
+func (e *UnexpectedFieldsError) Error() string {
+	var paths []string
+	for _, f := range e.Fields {
+		paths = append(paths, f.String())
+	}
+	return "unexpected fields: " + strings.Join(paths, ", ")
+}
+
+// DecodeStrict decodes the JSON document b into v like json.Unmarshal, but
+// fails with an *UnexpectedFieldsError if b has fields that v does not define.
+func DecodeStrict(b []byte, v interface{}) error {
+	if err := json.Unmarshal(b, v); err != nil {
+		return err
+	}
+	unknown, err := UnknownFields(b, v)
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		return &UnexpectedFieldsError{Fields: unknown}
+	}
+	return nil
+}
+
+// A Shim is a json.Unmarshaler that decodes the JSON objects it is given
+// into the value returned by Decoded, and converts that value to its own type.
+// UnknownFields and DecodeStrict look for unknown fields in the type of that
+// value.
+type Shim interface {
+	json.Unmarshaler
+	Decoded() interface{}
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	shimType        = reflect.TypeOf((*Shim)(nil)).Elem()
+)
+
+// UnknownFields returns the object members of the JSON document b that
+// encoding/json ignores when decoding b into v, sorted by path. Values
+// decoded by a custom UnmarshalJSON method are not looked into, unless their
+// type is a Shim.
+func UnknownFields(b []byte, v interface{}) ([]Field, error) {
+	doc, err := decodeJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	var unknown []Field
+	unknownFields(&unknown, nil, doc, reflect.TypeOf(v))
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].String() < unknown[j].String() })
+	return unknown, nil
+}
+
+func unknownFields(unknown *[]Field, path Field, doc interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(shimType) {
+		shim := reflect.New(t).Interface().(Shim)
+		unknownFields(unknown, path, doc, reflect.TypeOf(shim.Decoded()))
+		return
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		for k, v := range obj {
+			ft, ok := fields[k]
+			if !ok {
+				// encoding/json falls back to a case-insensitive match.
+				for name, typ := range fields {
+					if strings.EqualFold(name, k) {
+						ft, ok = typ, true
+						break
+					}
+				}
+			}
+			if !ok {
+				*unknown = append(*unknown, path.child(k))
+				continue
+			}
+			unknownFields(unknown, path.child(k), v, ft)
+		}
+	case reflect.Map:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, v := range obj {
+			unknownFields(unknown, path.child(k), v, t.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := doc.([]interface{})
+		if !ok {
+			return
+		}
+		for i, v := range arr {
+			unknownFields(unknown, path.child(i), v, t.Elem())
+		}
+	}
+}
+
+// jsonFields returns the types of the members of the JSON object that
+// encoding/json decodes into a struct of type t, keyed by name.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for k, v := range jsonFields(ft) {
+				if _, ok := fields[k]; !ok {
+					fields[k] = v
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// preserveUnknown copies the given fields of the JSON document from into the
+// JSON document to, at the same paths. It fails if the object holding a
+// field is not in to anymore.
+func preserveUnknown(from, to []byte, fields []Field) ([]byte, error) {
+	if len(fields) == 0 {
+		return to, nil
+	}
+	src, err := decodeJSON(from)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := decodeJSON(to)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		v, ok := lookupField(src, f)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %s not found", f)
+		}
+		parent, ok := lookupField(dst, f[:len(f)-1])
+		obj, isObj := parent.(map[string]interface{})
+		if !ok || !isObj {
+			return nil, fmt.Errorf("cannot preserve unknown field %s: its parent was removed", f)
+		}
+		k := f[len(f)-1].(string)
+		if _, ok := obj[k]; !ok {
+			obj[k] = v
+		}
+	}
+	b, err := json.Marshal(dst)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// checkUnmoved fails if the object holding one of the given fields of the JSON
+// document from is not found unchanged at the same path in the JSON document
+// to, as when to merges or reorders the elements of an array: the field could
+// not be preserved in to.
+func checkUnmoved(from, to []byte, fields []Field) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	src, err := decodeJSON(from)
+	if err != nil {
+		return err
+	}
+	dst, err := decodeJSON(to)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		a, _ := lookupField(src, f[:len(f)-1])
+		b, ok := lookupField(dst, f[:len(f)-1])
+		if !ok || !reflect.DeepEqual(a, b) {
+			return fmt.Errorf("cannot preserve unknown field %s: its parent was moved", f)
+		}
+	}
+	return nil
+}
+
+func lookupField(doc interface{}, f Field) (interface{}, bool) {
+	for _, p := range f {
+		switch p := p.(type) {
+		case string:
+			obj, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = obj[p]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := doc.([]interface{})
+			if !ok || p >= len(arr) {
+				return nil, false
+			}
+			doc = arr[p]
+		}
+	}
+	return doc, true
+}
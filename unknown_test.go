@@ -0,0 +1,104 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type opaque struct{}
+
+func (o *opaque) UnmarshalJSON(b []byte) error { return nil }
+
+type shim struct{}
+
+func (s *shim) UnmarshalJSON(b []byte) error { return nil }
+
+func (s *shim) Decoded() interface{} {
+	return &struct {
+		Known int `json:"known"`
+	}{}
+}
+
+type embedded struct {
+	Inline string `json:"inline"`
+}
+
+type unknownTest struct {
+	embedded
+	Name     string `json:"name"`
+	Ignored  string `json:"-"`
+	Untagged int
+	Items    []*unknownTest    `json:"items"`
+	Labels   map[string]string `json:"labels"`
+	Opaque   opaque            `json:"opaque"`
+	Shim     shim              `json:"shim"`
+}
+
+func TestUnknownFields(t *testing.T) {
+	doc := `{
+		"name": "a",
+		"NAME": "b",
+		"inline": "c",
+		"untagged": 1,
+		"Ignored": "d",
+		"labels": {"x/y": "z"},
+		"opaque": {"anything": true},
+		"shim": {"known": 1, "unknown": 2},
+		"items": [{"name": "e"}, {"extra": [1]}],
+		"other": {"nested": 1}
+	}`
+	fields, err := UnknownFields([]byte(doc), &unknownTest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range fields {
+		paths = append(paths, f.String())
+	}
+	expected := []string{".Ignored", ".items[1].extra", ".other", ".shim.unknown"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("%v | %v", expected, paths)
+	}
+	if p := fields[1].Pointer(); p != "/items/1/extra" {
+		t.Fatalf("pointer: %s", p)
+	}
+
+	var v unknownTest
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = preserveUnknown([]byte(doc), out, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields, err := UnknownFields(out, &unknownTest{}); err != nil || len(fields) != len(expected) {
+		t.Fatalf("unknown fields not preserved: %s %v", out, err)
+	}
+
+	if _, err := preserveUnknown([]byte(`{"a":{"b":1}}`), []byte(`{}`), []Field{{"a", "b"}}); err == nil {
+		t.Fatal("expected an error when the parent of a field is removed")
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	var v unknownTest
+	if err := DecodeStrict([]byte(`{"name":"a","items":[{"name":"b"}]}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	err := DecodeStrict([]byte(`{"name":"a","items":[{"nam":"b"}]}`), &v)
+	e, ok := err.(*UnexpectedFieldsError)
+	if !ok {
+		t.Fatalf("expected an *UnexpectedFieldsError, got %v", err)
+	}
+	if len(e.Fields) != 1 || e.Fields[0].String() != ".items[0].nam" {
+		t.Fatalf("fields: %v", e.Fields)
+	}
+	if s := e.Error(); s != "unexpected fields: .items[0].nam" {
+		t.Fatalf("error: %s", s)
+	}
+}
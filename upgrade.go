@@ -0,0 +1,447 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Document is a state file being upgraded.
+type Document struct {
+	Path    string
+	Kind    Kind
+	Version Version
+	Data    []byte
+	// Unknown holds the fields of Data that were not understood by the
+	// last conversion.
+	Unknown []Field
+	// Losses holds the information dropped by the last conversion.
+	Losses []Loss
+}
+
+// A Loss is information dropped by a conversion, typically to the format of
+// an earlier version that cannot represent it.
+type Loss struct {
+	Field  Field
+	Reason string
+}
+
+func (l Loss) String() string {
+	return fmt.Sprintf("%s: %s", l.Field, l.Reason)
+}
+
+// Options control Upgrade.
+type Options struct {
+	// Target is the version to upgrade the state files to.
+	Target Version
+	// DryRun converts the state files and reports the changes without
+	// writing anything.
+	DryRun bool
+	// PreserveUnknown copies the fields that the upgraders do not know
+	// about to the upgraded files, instead of dropping them. With
+	// Normalize, the upgrade fails if normalizing merges or reorders the
+	// objects holding such fields.
+	PreserveUnknown bool
+	// Capabilities expands the flat capability lists written before
+	// Docker 17.06 into capability sets; DockerCapabilities if nil.
+	Capabilities CapabilityPolicy
+	// Normalize rewrites the converted files in a canonical form where
+	// the upgraders support it, for example by merging the seccomp rules
+	// that only differ by their syscall; see Normalizer.
+	Normalize bool
+	// Strict fails the conversion of any file with fields that the
+	// upgraders do not know about. It takes precedence over
+	// PreserveUnknown.
+	Strict bool
+	// ProcRoot is where procfs is mounted, DefaultProcRoot if empty.
+	ProcRoot string
+	// IgnoreLiveness upgrades containers without checking that their init
+	// process is still running.
+	IgnoreLiveness bool
+	// Dead is what to do with containers whose init process is not
+	// running anymore. They are never upgraded.
+	Dead DeadPolicy
+	// LockTimeout is how long to wait for another upgrade of the same
+	// container to complete. Upgrade fails right away if zero.
+	LockTimeout time.Duration
+	// RefuseWriters fails the upgrade of containers with a runtime
+	// process that may write their state files, instead of only
+	// reporting them.
+	RefuseWriters bool
+	// Retention limits the backups of the state files kept for the
+	// container, taken before each upgrade.
+	Retention Retention
+	// SkipValidation writes the upgraded state files without checking that
+	// they agree with each other; see Validate.
+	SkipValidation bool
+	// AllowInvalid writes the upgraded state files even if they violate
+	// the specification of their format. The violations are reported
+	// either way; see ValidateDocument.
+	AllowInvalid bool
+	// Marker records the target version and the digests of the state
+	// files in a marker next to them after the upgrade. Containers whose
+	// files still match their marker are skipped without decoding them.
+	Marker bool
+}
+
+// Report describes the changes made by Upgrade, or that it would make in a
+// dry run.
+type Report struct {
+	// Liveness is the state of the init process of the container. If it
+	// is neither LivenessLive nor LivenessUnknown, nothing was upgraded.
+	Liveness Liveness
+	// Removed is set if the container was dead and its state files were
+	// removed; see DeadCleanup.
+	Removed bool
+	// Writers are the runtime processes of the container found running
+	// during the upgrade.
+	Writers []Writer
+	// Backup is the generation of the backup of the original files, if
+	// any were changed or removed.
+	Backup string
+	Files  []FileReport
+	// Processes holds the outcome for the state of each process of the
+	// container, the init process first then the exec processes.
+	Processes []ProcessReport
+}
+
+// ProcessReport is the outcome of an upgrade for the state of a single
+// process. The states of all the processes of a container are upgraded
+// together.
+type ProcessReport struct {
+	// ID is the libcontainerd name of the process; see ProcessID.
+	ID   string
+	Path string
+	// From is the version of the state before the upgrade.
+	From Version
+	// Changed is set if the state was converted; the changes are in
+	// Report.Files.
+	Changed bool
+}
+
+// FileReport describes the changes to a single state file.
+type FileReport struct {
+	Path     string
+	Kind     Kind
+	From, To Version
+	Diff     Diff
+	// Unknown holds the fields of the original file that the upgraders
+	// did not understand. They are dropped unless Options.PreserveUnknown
+	// is set.
+	Unknown []Field
+	// Losses holds the information that could not be converted.
+	Losses []Loss
+	// Violations holds the parts of the converted file that do not follow
+	// the specification of its format; see ValidateDocument.
+	Violations []Violation
+}
+
+func (c Container) documents() []*Document {
+	docs := []*Document{
+		{Path: c.State, Kind: KindState},
+		{Path: c.Config, Kind: KindConfig},
+		{Path: c.Process, Kind: KindProcess},
+	}
+	for _, path := range c.Execs {
+		docs = append(docs, &Document{Path: path, Kind: KindProcess})
+	}
+	return docs
+}
+
+// Load reads the state files of c, those of its exec processes included, and
+// detects the version of each of them. It fails if the version of any of the
+// files cannot be determined, or if an interrupted upgrade of c has not been
+// recovered.
+func Load(c Container) ([]*Document, error) {
+	c, err := c.withExecs()
+	if err != nil {
+		return nil, err
+	}
+	m, err := readMarker(c)
+	if err != nil {
+		return nil, err
+	}
+	return load(c, m)
+}
+
+func load(c Container, m *marker) ([]*Document, error) {
+	if _, err := c.fs().Stat(c.Journal()); err == nil {
+		return nil, &ConsistencyError{Container: c.ID, Files: []string{c.Journal()}, Reason: "an upgrade was interrupted"}
+	}
+	docs := c.documents()
+	for _, doc := range docs {
+		f, err := c.fs().Open(doc.Path)
+		if err != nil {
+			return nil, err
+		}
+		err = readDocument(doc, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		// the files written by an upgrade may look like an earlier
+		// version.
+		if v := m.version(doc.Path, doc.Data); v != "" {
+			doc.Version = v
+		}
+	}
+	return docs, nil
+}
+
+// Convert upgrades doc in memory to opts.Target, going through as many
+// upgraders as needed. The returned report has no Diff. Convert fails with a
+// *DecodeError if doc cannot be decoded, or wrapping an *UnexpectedFieldsError
+// if some fields are not understood by the upgraders and opts.Strict is set.
+// It fails with a *ConvertError if an upgrader cannot convert doc.
+func Convert(doc *Document, opts Options) (*FileReport, error) {
+	steps, err := Plan(doc.Version, opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	report := &FileReport{Path: doc.Path, Kind: doc.Kind, From: doc.Version, To: opts.Target}
+	for _, u := range steps {
+		data := doc.Data
+		doc.Unknown, doc.Losses = nil, nil
+		convert := u.Upgrade
+		if o, ok := u.(OptionsUpgrader); ok {
+			convert = func(doc *Document) error { return o.UpgradeOptions(doc, opts) }
+		}
+		if err := convert(doc); err != nil {
+			if isDecodeError(err) {
+				return nil, decodeError(doc, err)
+			}
+			return nil, &ConvertError{File: doc.Path, Kind: doc.Kind, From: u.From(), To: u.To(), Err: err}
+		}
+		if n, ok := u.(Normalizer); ok && opts.Normalize {
+			converted := doc.Data
+			if err := n.Normalize(doc); err != nil {
+				return nil, &ConvertError{File: doc.Path, Kind: doc.Kind, From: u.From(), To: u.To(), Err: err}
+			}
+			if opts.PreserveUnknown {
+				// the unknown fields are found at their paths in
+				// the converted document.
+				if err := checkUnmoved(converted, doc.Data, doc.Unknown); err != nil {
+					return nil, &ConvertError{File: doc.Path, Kind: doc.Kind, From: u.From(), To: u.To(), Err: err}
+				}
+			}
+		}
+		if opts.Strict && len(doc.Unknown) > 0 {
+			return nil, decodeError(doc, &UnexpectedFieldsError{Fields: doc.Unknown})
+		}
+		if opts.PreserveUnknown {
+			if doc.Data, err = preserveUnknown(data, doc.Data, doc.Unknown); err != nil {
+				return nil, &ConvertError{File: doc.Path, Kind: doc.Kind, From: u.From(), To: u.To(), Err: err}
+			}
+		}
+		report.Unknown = mergeFields(report.Unknown, doc.Unknown)
+		report.Losses = append(report.Losses, doc.Losses...)
+		doc.Version = u.To()
+	}
+	return report, nil
+}
+
+// mergeFields appends the fields of b that are not in a.
+func mergeFields(a, b []Field) []Field {
+	seen := make(map[string]bool)
+	for _, f := range a {
+		seen[f.Pointer()] = true
+	}
+	for _, f := range b {
+		if !seen[f.Pointer()] {
+			seen[f.Pointer()] = true
+			a = append(a, f)
+		}
+	}
+	return a
+}
+
+// configUser returns the user of the process of the config among docs, nil if
+// it is not found.
+func configUser(docs []*Document) *uint32 {
+	for _, doc := range docs {
+		if doc.Kind != KindConfig {
+			continue
+		}
+		var spec struct {
+			Process struct {
+				User struct {
+					UID *uint32 `json:"uid"`
+				} `json:"user"`
+			} `json:"process"`
+		}
+		if err := json.Unmarshal(doc.Data, &spec); err != nil {
+			return nil
+		}
+		return spec.Process.User.UID
+	}
+	return nil
+}
+
+// NeedsUpgrade reports whether any of the state files of c is not in the
+// format of the target version, or whether an upgrade of c was interrupted.
+func NeedsUpgrade(c Container, target Version) (bool, error) {
+	if _, err := c.fs().Stat(c.Journal()); err == nil {
+		return true, nil
+	}
+	c, err := c.withExecs()
+	if err != nil {
+		return false, err
+	}
+	m, err := readMarker(c)
+	if err != nil {
+		return false, err
+	}
+	if current, err := m.current(c, target); err != nil || current {
+		return false, err
+	}
+	docs, err := load(c, m)
+	if err != nil {
+		return false, err
+	}
+	for _, doc := range docs {
+		file, err := upgradeDocument(doc, Options{Target: target})
+		if err != nil {
+			return false, err
+		}
+		if file != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Upgrade converts the state files of c, those of the exec processes found in
+// its directory included, to opts.Target. Files that are already at the
+// target version, or in its format, are left untouched; so are containers
+// whose marker shows them at the target, see Options.Marker. The files are
+// replaced all together or not at all; an earlier upgrade of c that was
+// interrupted is recovered first, unless opts.DryRun is set.
+//
+// Unless opts.IgnoreLiveness is set, the container is only upgraded if its init
+// process is still running; otherwise it is handled according to opts.Dead.
+//
+// The state directories of c are locked for the duration of the upgrade; see
+// Lock and FindWriters. The converted files are checked against the
+// specification of their format before anything is written: if any is
+// invalid, the report is returned along with Errors of *InvalidError, unless
+// opts.AllowInvalid is set. Unless opts.SkipValidation is set, they are also
+// checked to agree with each other. The original files are saved in a new
+// backup before being replaced, and older backups are pruned according to
+// opts.Retention.
+func Upgrade(c Container, opts Options) (*Report, error) {
+	unlock, err := Lock(c, opts.LockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	// the exec processes cannot come and go while the directories are
+	// locked.
+	if c, err = c.withExecs(); err != nil {
+		return nil, err
+	}
+	procRoot := opts.ProcRoot
+	if procRoot == "" {
+		procRoot = DefaultProcRoot
+	}
+	report := &Report{}
+	if report.Writers, err = FindWriters(procRoot, c); err != nil {
+		return nil, fmt.Errorf("error looking for the runtime processes of %s: %w", c.ID, err)
+	}
+	if len(report.Writers) > 0 && opts.RefuseWriters {
+		return nil, fmt.Errorf("not upgrading %s: %s may write its state files", c.ID, report.Writers[0])
+	}
+	if !opts.DryRun {
+		if err := Recover(c); err != nil {
+			return nil, fmt.Errorf("error recovering interrupted upgrade of %s: %w", c.ID, err)
+		}
+	}
+	if !opts.IgnoreLiveness {
+		if report.Liveness, err = CheckLiveness(procRoot, c); err != nil {
+			return nil, fmt.Errorf("error checking liveness of %s: %w", c.ID, err)
+		}
+		if report.Liveness != LivenessLive {
+			if opts.Dead == DeadCleanup && !opts.DryRun {
+				if report.Backup, err = c.cleanup(opts.Retention); err != nil {
+					return nil, err
+				}
+				report.Removed = true
+			}
+			return report, nil
+		}
+	}
+	m, err := readMarker(c)
+	if err != nil {
+		return nil, err
+	}
+	if current, err := m.current(c, opts.Target); err != nil || current {
+		return report, err
+	}
+	docs, err := load(c, m)
+	if err != nil {
+		return nil, err
+	}
+	var changed []*Document
+	sources := make(map[string]string)
+	// the runc state does not record the user of the init process, which
+	// is the one of the config.
+	stateOpts := opts
+	stateOpts.Capabilities = opts.Capabilities.forUser(configUser(docs))
+	for _, doc := range docs {
+		sources[doc.Path] = digest(doc.Data)
+		from := doc.Version
+		docOpts := opts
+		if doc.Kind == KindState {
+			docOpts = stateOpts
+		}
+		// error out if any of the files cannot be converted before
+		// overwriting them, to prevent being in a mixed state.
+		file, err := upgradeDocument(doc, docOpts)
+		if err != nil {
+			return nil, err
+		}
+		if doc.Kind == KindProcess {
+			report.Processes = append(report.Processes, ProcessReport{ID: ProcessID(doc.Path), Path: doc.Path, From: from, Changed: file != nil})
+		}
+		if file == nil {
+			continue
+		}
+		report.Files = append(report.Files, *file)
+		changed = append(changed, doc)
+	}
+	if err := invalid(report.Files, opts); err != nil {
+		return report, err
+	}
+	if !opts.SkipValidation {
+		if err := Validate(c, docs, opts.Target); err != nil {
+			return nil, err
+		}
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+	if len(changed) > 0 {
+		backup, err := CreateBackup(c)
+		if err != nil {
+			return nil, fmt.Errorf("error backing up the state files of %s: %w", c.ID, err)
+		}
+		report.Backup = backup.Generation
+		if _, err := PruneBackups(c, opts.Retention); err != nil {
+			return nil, fmt.Errorf("error pruning the backups of %s: %w", c.ID, err)
+		}
+		if err := commit(c.fs(), c.Journal(), changed); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Marker {
+		if err := writeMarker(c, opts.Target, docs, sources); err != nil {
+			return nil, fmt.Errorf("error writing the version marker of %s: %w", c.ID, err)
+		}
+	} else if m != nil && len(changed) > 0 {
+		// the marker no longer holds.
+		if err := c.fs().Remove(c.Marker()); err != nil && !os.IsNotExist(err) {
+			return nil, &WriteError{File: c.Marker(), Op: "marker", Err: err}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,116 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestUpgradeCapabilities(t *testing.T) {
+	caps := []string{"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_FSETID", "CAP_FOWNER", "CAP_MKNOD", "CAP_NET_RAW", "CAP_SETGID", "CAP_SETUID", "CAP_SETFCAP", "CAP_SETPCAP", "CAP_NET_BIND_SERVICE", "CAP_SYS_CHROOT", "CAP_KILL", "CAP_AUDIT_WRITE"}
+	all := &specs.LinuxCapabilities{Bounding: caps, Effective: caps, Inheritable: caps, Permitted: caps}
+	noInheritable := &specs.LinuxCapabilities{Bounding: caps, Effective: caps, Permitted: caps}
+	ambient := &specs.LinuxCapabilities{Bounding: caps, Effective: caps, Inheritable: caps, Permitted: caps, Ambient: caps}
+
+	for _, d := range [...]struct {
+		name   string
+		policy upgrade.CapabilityPolicy
+		uid    string
+		expect *specs.LinuxCapabilities
+	}{
+		{"default", nil, "0", all},
+		{"default non-root", nil, "1000", all},
+		{"docker", upgrade.DockerCapabilities, "1000", all},
+		{"no-inheritable", upgrade.NoInheritableCapabilities, "0", noInheritable},
+		{"non-root-ambient root", upgrade.NonRootAmbientCapabilities, "0", all},
+		{"non-root-ambient", upgrade.NonRootAmbientCapabilities, "1000", ambient},
+	} {
+		edit := func(kind upgrade.Kind, b []byte) []byte {
+			if kind == upgrade.KindState {
+				// the runc state does not record the user: its
+				// capabilities are expanded for the user of the
+				// config, or the validation fails.
+				list, _ := json.Marshal(caps)
+				return bytes.Replace(b, []byte(`"capabilities":null`), []byte(`"capabilities":`+string(list)), 1)
+			}
+			return bytes.Replace(b, []byte(`"uid":0,`), []byte(`"uid":`+d.uid+`,`), -1)
+		}
+		c, fs := fixtureContainer(t, "17.03", edit)
+		if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, Capabilities: d.policy}); err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+
+		var (
+			state   State
+			spec    Spec
+			process ProcessState
+		)
+		for path, x := range map[string]interface{}{c.State: &state, c.Config: &spec, c.Process: &process} {
+			b, err := readFile(fs, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(b, x); err != nil {
+				t.Fatalf("%s: %v", d.name, err)
+			}
+		}
+		if !reflect.DeepEqual(spec.Process.Capabilities.V, d.expect) {
+			t.Fatalf("%s: config: expected %+v, got %+v", d.name, d.expect, spec.Process.Capabilities.V)
+		}
+		if !reflect.DeepEqual(process.Capabilities.V, d.expect) {
+			t.Fatalf("%s: process: expected %+v, got %+v", d.name, d.expect, process.Capabilities.V)
+		}
+		if !reflect.DeepEqual(state.Config.Capabilities.V, d.expect) {
+			t.Fatalf("%s: state: expected %+v, got %+v", d.name, d.expect, state.Config.Capabilities.V)
+		}
+	}
+
+	// the capability sets written by 17.06 are left as they are.
+	c, fs := fixtureContainer(t, "17.06.0", nil)
+	original, err := readFile(fs, c.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var before Spec
+	if err := json.Unmarshal(original, &before); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, Capabilities: upgrade.NoInheritableCapabilities}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := readFile(fs, c.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var after Spec
+	if err := json.Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before.Process.Capabilities.V, after.Process.Capabilities.V) {
+		t.Fatalf("expected %+v, got %+v", before.Process.Capabilities.V, after.Process.Capabilities.V)
+	}
+}
+
+func TestParseCapabilityPolicy(t *testing.T) {
+	uid := uint32(1000)
+	for name, expect := range map[string][]string{
+		"docker":           nil,
+		"no-inheritable":   nil,
+		"non-root-ambient": {"CAP_KILL"},
+	} {
+		policy, err := upgrade.ParseCapabilityPolicy(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if sets := policy([]string{"CAP_KILL"}, &uid); !reflect.DeepEqual(sets.Ambient, expect) {
+			t.Fatalf("%s: expected %v, got %v", name, expect, sets.Ambient)
+		}
+	}
+	if _, err := upgrade.ParseCapabilityPolicy("all"); err == nil {
+		t.Fatal("expected an error for an unknown policy")
+	}
+}
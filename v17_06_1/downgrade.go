@@ -0,0 +1,256 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+func init() {
+	for _, v := range []upgrade.Version{upgrade.V17_03, upgrade.V17_05} {
+		upgrade.Register(downgrader{to: v})
+	}
+	// 17.03 and 17.05 write the same formats, only the default seccomp
+	// profile differs.
+	upgrade.Register(sameFormat{upgrade.V17_03, upgrade.V17_05})
+	upgrade.Register(sameFormat{upgrade.V17_05, upgrade.V17_03})
+}
+
+// downgrader converts the files written by Docker 17.06.1 back to the format
+// of 17.03 and 17.05, so that an engine rolled back to one of these versions
+// can restore the containers. It works on the JSON documents directly, and
+// keeps the fields it does not know about.
+type downgrader struct {
+	to upgrade.Version
+}
+
+func (d downgrader) From() upgrade.Version { return Version }
+
+func (d downgrader) To() upgrade.Version { return d.to }
+
+func (d downgrader) Upgrade(doc *upgrade.Document) error {
+	var v map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(doc.Data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return &upgrade.DecodeError{Err: err}
+	}
+	var (
+		losses []upgrade.Loss
+		err    error
+	)
+	switch doc.Kind {
+	case upgrade.KindState:
+		losses, err = downgradeState(v)
+	case upgrade.KindConfig:
+		losses, err = downgradeSpec(v)
+	case upgrade.KindProcess:
+		addConsoleSize(v)
+		err = flattenCapabilities(v, "capabilities", upgrade.Field{"capabilities"})
+	default:
+		err = fmt.Errorf("unsupported document kind: %v", doc.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	doc.Data = buf.Bytes()
+	doc.Losses = losses
+	return nil
+}
+
+func downgradeState(v map[string]interface{}) ([]upgrade.Loss, error) {
+	var losses []upgrade.Loss
+	if n, ok := v["init_process_start"].(json.Number); ok {
+		v["init_process_start"] = n.String()
+	}
+	config, _ := v["config"].(map[string]interface{})
+	for _, d := range []struct {
+		obj   map[string]interface{}
+		field upgrade.Field
+	}{
+		{v, upgrade.Field{"rootless"}},
+		{config, upgrade.Field{"config", "rootless"}},
+	} {
+		if d.obj == nil {
+			continue
+		}
+		if rootless, _ := d.obj["rootless"].(bool); rootless {
+			losses = append(losses, upgrade.Loss{Field: d.field, Reason: "rootless containers are not supported before 17.06"})
+		}
+		delete(d.obj, "rootless")
+	}
+	if config != nil {
+		if err := flattenCapabilities(config, "capabilities", upgrade.Field{"config", "capabilities"}); err != nil {
+			return nil, err
+		}
+		// runc wrote the default swappiness -1, and null since.
+		if cgroups, ok := config["cgroups"].(map[string]interface{}); ok {
+			if s, ok := cgroups["memory_swappiness"]; ok && (s == nil || s == json.Number("18446744073709551615")) {
+				cgroups["memory_swappiness"] = -1
+			}
+		}
+	}
+	return losses, nil
+}
+
+// blockIONames maps the names of the block IO settings of runtime-spec
+// v1.0.0-rc5 to the earlier ones.
+var blockIONames = map[string]string{
+	"weight":                  "blkioWeight",
+	"leafWeight":              "blkioLeafWeight",
+	"weightDevice":            "blkioWeightDevice",
+	"throttleReadBpsDevice":   "blkioThrottleReadBpsDevice",
+	"throttleWriteBpsDevice":  "blkioThrottleWriteBpsDevice",
+	"throttleReadIOPSDevice":  "blkioThrottleReadIOPSDevice",
+	"throttleWriteIOPSDevice": "blkioThrottleWriteIOPSDevice",
+}
+
+func downgradeSpec(v map[string]interface{}) ([]upgrade.Loss, error) {
+	var losses []upgrade.Loss
+	process, _ := v["process"].(map[string]interface{})
+	linux, _ := v["linux"].(map[string]interface{})
+	if _, ok := v["platform"]; !ok {
+		// the platform was always set to the one of the daemon, which
+		// 17.06.1 does not record: assume it is this one.
+		v["platform"] = map[string]interface{}{"os": runtime.GOOS, "arch": runtime.GOARCH}
+		losses = append(losses, upgrade.Loss{Field: upgrade.Field{"platform"}, Reason: fmt.Sprintf("not recorded, set to %s/%s", runtime.GOOS, runtime.GOARCH)})
+	}
+	if process != nil {
+		addConsoleSize(process)
+		if err := flattenCapabilities(process, "capabilities", upgrade.Field{"process", "capabilities"}); err != nil {
+			return nil, err
+		}
+		if oomScoreAdj, ok := process["oomScoreAdj"]; ok {
+			// the OOM score adjustment was a cgroup resource.
+			if linux == nil {
+				losses = append(losses, upgrade.Loss{Field: upgrade.Field{"process", "oomScoreAdj"}, Reason: "no linux section to move it to"})
+			} else {
+				resources, _ := linux["resources"].(map[string]interface{})
+				if resources == nil {
+					resources = make(map[string]interface{})
+					linux["resources"] = resources
+				}
+				resources["oomScoreAdj"] = oomScoreAdj
+			}
+			delete(process, "oomScoreAdj")
+		}
+	}
+	if linux == nil {
+		return losses, nil
+	}
+	if resources, ok := linux["resources"].(map[string]interface{}); ok {
+		if blockIO, ok := resources["blockIO"].(map[string]interface{}); ok {
+			for name, old := range blockIONames {
+				if value, ok := blockIO[name]; ok {
+					blockIO[old] = value
+					delete(blockIO, name)
+				}
+			}
+		}
+	}
+	seccomp, _ := linux["seccomp"].(map[string]interface{})
+	if seccomp == nil {
+		return losses, nil
+	}
+	syscalls, ok := seccomp["syscalls"].([]interface{})
+	if !ok {
+		return losses, nil
+	}
+	// rules applied to several syscalls are split into one rule per
+	// syscall.
+	var split []interface{}
+	for i, s := range syscalls {
+		rule, ok := s.(map[string]interface{})
+		if !ok {
+			split = append(split, s)
+			continue
+		}
+		names, ok := rule["names"].([]interface{})
+		if !ok {
+			split = append(split, rule)
+			continue
+		}
+		if len(names) == 0 {
+			losses = append(losses, upgrade.Loss{
+				Field:  upgrade.Field{"linux", "seccomp", "syscalls", i},
+				Reason: "rule without syscall names dropped",
+			})
+		}
+		for _, name := range names {
+			r := make(map[string]interface{}, len(rule))
+			for k, v := range rule {
+				if k != "names" {
+					r[k] = v
+				}
+			}
+			r["name"] = name
+			split = append(split, r)
+		}
+	}
+	seccomp["syscalls"] = split
+	return losses, nil
+}
+
+// addConsoleSize adds the empty console size that the earlier versions always
+// wrote in processes.
+func addConsoleSize(process map[string]interface{}) {
+	if _, ok := process["consoleSize"]; !ok {
+		process["consoleSize"] = map[string]interface{}{"height": 0, "width": 0}
+	}
+}
+
+// flattenCapabilities replaces the capability sets found in obj[key] with a
+// single list. It refuses to do so if the sets differ or if there are ambient
+// capabilities, as the containers would not be restored with the same
+// privileges.
+func flattenCapabilities(obj map[string]interface{}, key string, field upgrade.Field) error {
+	caps, ok := obj[key].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	// libcontainer has no json tags on its capabilities.
+	set := func(name string) []interface{} {
+		for k, v := range caps {
+			if strings.EqualFold(k, name) {
+				list, _ := v.([]interface{})
+				return list
+			}
+		}
+		return nil
+	}
+	if len(set("ambient")) > 0 {
+		return fmt.Errorf("cannot convert %s: ambient capabilities are not supported before 17.06", field)
+	}
+	bounding := set("bounding")
+	for _, name := range []string{"effective", "inheritable", "permitted"} {
+		s := set(name)
+		if len(s) == 0 && len(bounding) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(s, bounding) {
+			return fmt.Errorf("cannot convert %s: the %s and bounding capability sets differ", field, name)
+		}
+	}
+	obj[key] = bounding
+	return nil
+}
+
+// sameFormat converts between two versions that write the same formats.
+type sameFormat struct {
+	from, to upgrade.Version
+}
+
+func (s sameFormat) From() upgrade.Version { return s.from }
+
+func (s sameFormat) To() upgrade.Version { return s.to }
+
+func (s sameFormat) Upgrade(doc *upgrade.Document) error { return nil }
@@ -0,0 +1,138 @@
+package v17_06_1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+func downgrade(t *testing.T, kind upgrade.Kind, data []byte) *upgrade.Document {
+	doc := &upgrade.Document{Kind: kind, Version: Version, Data: data}
+	if err := (downgrader{to: upgrade.V17_05}).Upgrade(doc); err != nil {
+		t.Fatalf("downgrade %s: %v", kind, err)
+	}
+	return doc
+}
+
+func TestDowngradeFixtures(t *testing.T) {
+	for _, d := range [...]struct {
+		name string
+		kind upgrade.Kind
+		// losses are the fields reported lost.
+		losses []string
+	}{
+		{"state.json", upgrade.KindState, nil},
+		{"config.json", upgrade.KindConfig, []string{".platform"}},
+		{"process.json", upgrade.KindProcess, nil},
+	} {
+		name := filepath.Join("..", "testfiles", d.name+"-17.06.1")
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := downgrade(t, d.kind, b)
+		var losses []string
+		for _, loss := range doc.Losses {
+			losses = append(losses, loss.Field.String())
+		}
+		if !reflect.DeepEqual(losses, d.losses) {
+			t.Fatalf("%s: unexpected losses: %v", name, doc.Losses)
+		}
+		if v, _, evidence := upgrade.DetectVersion(d.kind, doc.Data); v != upgrade.V17_03 && v != upgrade.V17_05 {
+			t.Fatalf("%s: downgraded to %s, evidence: %v", name, v, evidence)
+		}
+
+		// the files upgraded back must have the same contents.
+		var original, roundtrip interface{}
+		switch d.kind {
+		case upgrade.KindState:
+			original, roundtrip = new(State), new(State)
+		case upgrade.KindConfig:
+			original, roundtrip = new(Spec), new(Spec)
+		case upgrade.KindProcess:
+			original, roundtrip = new(ProcessState), new(ProcessState)
+		}
+		if err := json.Unmarshal(b, original); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(doc.Data, roundtrip); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if s, ok := roundtrip.(*State); ok {
+			// 17.05 writes -1 for the default swappiness, which
+			// 17.06.1 writes null.
+			if o := original.(*State).Config.Cgroups.MemorySwappiness; MemorySwappiness(o).Compare(MemorySwappiness(s.Config.Cgroups.MemorySwappiness)) == 0 {
+				s.Config.Cgroups.MemorySwappiness = o
+			}
+		}
+		if spec, ok := roundtrip.(*Spec); ok {
+			// 17.06.1 writes the OOM score adjustment in the process,
+			// which Spec does not have, and no platform.
+			spec.Linux.Resources.OOMScoreAdj = nil
+			spec.Platform = original.(*Spec).Platform
+		}
+		// the upgrade drops the empty console size added back.
+		dropConsoleSize(roundtrip)
+		if !reflect.DeepEqual(original, roundtrip) {
+			t.Fatalf("%s: the downgraded file does not upgrade back to the original", name)
+		}
+	}
+}
+
+func TestDowngradeSpec(t *testing.T) {
+	doc := downgrade(t, upgrade.KindConfig, []byte(`{
+		"process": {"oomScoreAdj": 100, "capabilities": {"bounding": ["CAP_KILL"], "effective": ["CAP_KILL"], "inheritable": ["CAP_KILL"], "permitted": ["CAP_KILL"]}},
+		"linux": {
+			"resources": {"blockIO": {"weight": 10}},
+			"seccomp": {"syscalls": [{"names": ["read", "write"], "action": "SCMP_ACT_ALLOW"}, {"names": [], "action": "SCMP_ACT_ALLOW"}]}
+		}
+	}`))
+	expected := `{"linux":{"resources":{"blockIO":{"blkioWeight":10},"oomScoreAdj":100},` +
+		`"seccomp":{"syscalls":[{"action":"SCMP_ACT_ALLOW","name":"read"},{"action":"SCMP_ACT_ALLOW","name":"write"}]}},` +
+		`"platform":{"arch":"` + runtime.GOARCH + `","os":"` + runtime.GOOS + `"},` +
+		`"process":{"capabilities":["CAP_KILL"],"consoleSize":{"height":0,"width":0}}}`
+	if s := strings.TrimSpace(string(doc.Data)); s != expected {
+		t.Fatalf("%s |\n%s", expected, s)
+	}
+	if len(doc.Losses) != 2 || doc.Losses[0].Field.String() != ".platform" || doc.Losses[1].Field.String() != ".linux.seccomp.syscalls[1]" {
+		t.Fatalf("losses: %v", doc.Losses)
+	}
+	if reason := doc.Losses[0].Reason; !strings.Contains(reason, runtime.GOOS+"/"+runtime.GOARCH) {
+		t.Fatalf("platform loss: %s", reason)
+	}
+
+	// a platform recorded in the document is kept.
+	doc = downgrade(t, upgrade.KindConfig, []byte(`{"platform":{"os":"linux","arch":"arm64"}}`))
+	if s := strings.TrimSpace(string(doc.Data)); s != `{"platform":{"arch":"arm64","os":"linux"}}` || len(doc.Losses) != 0 {
+		t.Fatalf("%s, losses: %v", s, doc.Losses)
+	}
+}
+
+func TestDowngradeState(t *testing.T) {
+	doc := downgrade(t, upgrade.KindState, []byte(`{"init_process_start":8497004,"rootless":true,"config":{"rootless":false,"capabilities":null}}`))
+	expected := `{"config":{"capabilities":null},"init_process_start":"8497004"}`
+	if s := strings.TrimSpace(string(doc.Data)); s != expected {
+		t.Fatalf("%s | %s", expected, s)
+	}
+	if len(doc.Losses) != 1 || doc.Losses[0].Field.String() != ".rootless" {
+		t.Fatalf("losses: %v", doc.Losses)
+	}
+}
+
+func TestDowngradeCapabilities(t *testing.T) {
+	for _, caps := range []string{
+		`{"bounding": ["CAP_KILL"], "effective": [], "inheritable": ["CAP_KILL"], "permitted": ["CAP_KILL"]}`,
+		`{"bounding": ["CAP_KILL"], "effective": ["CAP_KILL"], "inheritable": ["CAP_KILL"], "permitted": ["CAP_KILL"], "ambient": ["CAP_KILL"]}`,
+	} {
+		doc := &upgrade.Document{Kind: upgrade.KindProcess, Version: Version, Data: []byte(`{"capabilities":` + caps + `}`)}
+		if err := (downgrader{to: upgrade.V17_03}).Upgrade(doc); err == nil {
+			t.Fatalf("%s: expected an error", caps)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+func TestUpgradeDecodeError(t *testing.T) {
+	c, _ := fixtureContainer(t, "17.03", func(kind upgrade.Kind, b []byte) []byte {
+		if kind != upgrade.KindConfig {
+			return b
+		}
+		return bytes.Replace(b, []byte(`"terminal":true`), []byte(`"terminal":"yes"`), 1)
+	})
+	_, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true})
+	var e *upgrade.DecodeError
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.File != c.Config || e.Kind != upgrade.KindConfig || e.Offset == 0 || e.JSONPath == "" {
+		t.Fatalf("%+v", e)
+	}
+}
@@ -0,0 +1,141 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+// addExec writes the state of an exec process of c, derived from its init
+// process.
+func addExec(t *testing.T, c upgrade.Container, fs *upgrade.MemFS, id string, edit func([]byte) []byte) string {
+	b, err := readFile(fs, c.Process)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = bytes.Replace(b, []byte(`"exec":false`), []byte(`"exec":true`), 1)
+	b = bytes.Replace(b, []byte(`"/sleeping-beauty"`), []byte(`"/bin/sh"`), 1)
+	b = bytes.Replace(b, []byte("/init-std"), []byte("/"+id+"-std"), -1)
+	if edit != nil {
+		b = edit(b)
+	}
+	path := filepath.Join(filepath.Dir(c.Process), id+upgrade.ProcessSuffix)
+	if err := fs.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUpgradeExecs(t *testing.T) {
+	c, fs := fixtureContainer(t, "17.03", nil)
+	execs := []string{addExec(t, c, fs, "e2", nil), addExec(t, c, fs, "e1", nil)}
+	original := make(map[string][]byte)
+	for _, path := range append([]string{c.State, c.Config, c.Process}, execs...) {
+		b, err := readFile(fs, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		original[path] = b
+	}
+
+	report, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 5 || len(report.Processes) != 3 {
+		t.Fatalf("%+v", report)
+	}
+	for i, id := range []string{"init", "e1", "e2"} {
+		p := report.Processes[i]
+		if p.ID != id || !p.Changed || filepath.Base(p.Path) != id+upgrade.ProcessSuffix {
+			t.Fatalf("process %d: %+v", i, p)
+		}
+	}
+	for _, path := range execs {
+		b, err := readFile(fs, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(b, []byte(`"capabilities":{"bounding":`)) {
+			t.Fatalf("%s not upgraded: %s", path, b)
+		}
+	}
+	backups, err := upgrade.ListBackups(c)
+	if err != nil || len(backups) != 1 || len(backups[0].Files) != 5 {
+		t.Fatalf("%v, %v", backups, err)
+	}
+
+	// the exec processes are rolled back with the others.
+	if _, err := upgrade.Rollback(c, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	for path, b := range original {
+		restored, err := readFile(fs, path)
+		if err != nil || !bytes.Equal(restored, b) {
+			t.Fatalf("%s not restored: %v", path, err)
+		}
+	}
+}
+
+func TestUpgradeExecsTransaction(t *testing.T) {
+	c, fs := fixtureContainer(t, "17.03", nil)
+	addExec(t, c, fs, "e1", nil)
+	broken := addExec(t, c, fs, "e2", func(b []byte) []byte {
+		return bytes.Replace(b, []byte(`"cwd":"/"`), []byte(`"cwd":1`), 1)
+	})
+	var err error
+	if c.Execs, err = upgrade.FindExecs(c); err != nil || len(c.Execs) != 2 {
+		t.Fatalf("%v, %v", c.Execs, err)
+	}
+	original := make(map[string][]byte)
+	for _, path := range c.Files() {
+		if original[path], _ = readFile(fs, path); original[path] == nil {
+			t.Fatalf("%s not found", path)
+		}
+	}
+
+	_, err = upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true})
+	var e *upgrade.DecodeError
+	if !errors.As(err, &e) || e.File != broken {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// nothing is written if the state of any process cannot be converted.
+	for path, b := range original {
+		if actual, err := readFile(fs, path); err != nil || !bytes.Equal(actual, b) {
+			t.Fatalf("%s written: %v", path, err)
+		}
+	}
+}
+
+func TestRollbackExitedExec(t *testing.T) {
+	c, fs := fixtureContainer(t, "17.03", nil)
+	exited := addExec(t, c, fs, "e1", nil)
+	running := addExec(t, c, fs, "e2", nil)
+	original, err := readFile(fs, running)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove(exited); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := upgrade.Rollback(c, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Skipped) != 1 || b.Skipped[0] != exited {
+		t.Fatalf("skipped: %v", b.Skipped)
+	}
+	if _, err := fs.Stat(exited); err == nil {
+		t.Fatalf("%s restored", exited)
+	}
+	if restored, err := readFile(fs, running); err != nil || !bytes.Equal(restored, original) {
+		t.Fatalf("%s not restored: %v", running, err)
+	}
+}
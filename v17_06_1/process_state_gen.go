@@ -7,7 +7,7 @@ import specs "github.com/opencontainers/runtime-spec/specs-go" // a45ba0989fc26c
 
 type ProcessState struct {
 	Terminal        bool                `json:"terminal,omitempty"`
-	ConsoleSize     specs.Box           `json:"consoleSize,omitempty"`
+	ConsoleSize     *specs.Box          `json:"consoleSize,omitempty"`
 	User            specs.User          `json:"user"`
 	Args            []string            `json:"args"`
 	Env             []string            `json:"env,omitempty"`
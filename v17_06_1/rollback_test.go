@@ -0,0 +1,143 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+// fixtureID is the ID of the container of the fixtures.
+const fixtureID = "50f0834dd21e3e7a03f7373d2146d9026f7ebac96c6786015d78739cb299387f"
+
+// fixtureContainer returns a container on an in-memory file system with the
+// fixtures of the given version as state files, passed through edit if not
+// nil.
+func fixtureContainer(t *testing.T, version string, edit func(kind upgrade.Kind, b []byte) []byte) (upgrade.Container, *upgrade.MemFS) {
+	fs := upgrade.NewMemFS()
+	c := upgrade.NewContainer("/run/docker/runtime-runc/moby", "/var/run/docker/libcontainerd", fixtureID)
+	c.FS = fs
+	for _, f := range []struct {
+		path, fixture string
+		kind          upgrade.Kind
+	}{
+		{c.State, "state.json", upgrade.KindState},
+		{c.Config, "config.json", upgrade.KindConfig},
+		{c.Process, "process.json", upgrade.KindProcess},
+	} {
+		b, err := ioutil.ReadFile(filepath.Join("..", "testfiles", f.fixture+"-"+version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if edit != nil {
+			b = edit(f.kind, b)
+		}
+		if err := fs.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.WriteFile(f.path, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c, fs
+}
+
+func readFile(fs upgrade.FS, path string) ([]byte, error) {
+	r, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func TestUpgradeRollback(t *testing.T) {
+	c, fs := fixtureContainer(t, "17.03", nil)
+	original := make(map[string][]byte)
+	for _, path := range c.Files() {
+		b, err := readFile(fs, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		original[path] = b
+	}
+
+	report, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 3 || report.Backup == "" {
+		t.Fatalf("report: %+v", report)
+	}
+	b, err := upgrade.Rollback(c, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Generation != report.Backup {
+		t.Fatalf("restored %s, expected %s", b.Generation, report.Backup)
+	}
+	for path, expected := range original {
+		restored, err := readFile(fs, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(restored, expected) {
+			t.Fatalf("%s was not restored", path)
+		}
+	}
+}
+
+func TestUpgradeIdempotent(t *testing.T) {
+	for _, marker := range []bool{false, true} {
+		c, fs := fixtureContainer(t, "17.03", nil)
+		opts := upgrade.Options{Target: Version, IgnoreLiveness: true, Marker: marker}
+		if _, err := upgrade.Upgrade(c, opts); err != nil {
+			t.Fatalf("marker %v: %v", marker, err)
+		}
+		if _, err := fs.Stat(c.Marker()); (err == nil) != marker {
+			t.Fatalf("marker %v: %v", marker, err)
+		}
+
+		// nothing is written by a second upgrade.
+		fs.Fail = func(op, name string) error {
+			if op == "write" || op == "rename" {
+				t.Fatalf("marker %v: unexpected %s of %s", marker, op, name)
+			}
+			return nil
+		}
+		report, err := upgrade.Upgrade(c, opts)
+		if err != nil {
+			t.Fatalf("marker %v: %v", marker, err)
+		}
+		if len(report.Files) != 0 {
+			t.Fatalf("marker %v: upgraded again: %+v", marker, report.Files)
+		}
+		if needed, err := upgrade.NeedsUpgrade(c, Version); err != nil || needed {
+			t.Fatalf("marker %v: needs upgrade: %v, %v", marker, needed, err)
+		}
+		fs.Fail = nil
+		// the upgraded files are recognized as such, with or without
+		// the marker.
+		docs, err := upgrade.Load(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, doc := range docs {
+			if doc.Version != Version {
+				t.Fatalf("marker %v: %s detected as %s", marker, doc.Path, doc.Version)
+			}
+		}
+		if !marker {
+			continue
+		}
+		// the marker does not hold for files changed since.
+		if err := fs.WriteFile(c.Process, []byte(`{}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if needed, err := upgrade.NeedsUpgrade(c, Version); err == nil && !needed {
+			t.Fatal("a changed file was trusted to be upgraded")
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package v17_06_1
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/crosbymichael/upgrade"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+//go:generate go run ../gen/embed-files.go -- schema_gen.go schemaFiles schema/config-schema.json schema/config-linux.json schema/defs.json schema/defs-linux.json
+
+// configSchema is the JSON Schema of the config.json of the runtime-spec
+// revision vendored by the containerd of this version; see schema/README.md.
+var configSchema *upgrade.Schema
+
+func init() {
+	var err error
+	if configSchema, err = upgrade.CompileSchema(schemaFiles, "config-schema.json"); err != nil {
+		panic(err)
+	}
+	// containerd and runc decode null as an absent field.
+	configSchema.IgnoreNull = true
+}
+
+// ValidateDocument checks a config.json against the schema of the runtime
+// spec, then checks the values that the schema does not constrain but runc
+// would reject or misinterpret. The other documents are not checked.
+func (v validator) ValidateDocument(doc *upgrade.Document) ([]upgrade.Violation, error) {
+	if doc.Kind != upgrade.KindConfig {
+		return nil, nil
+	}
+	violations, err := configSchema.Validate(doc.Data)
+	if err != nil {
+		return nil, &upgrade.DecodeError{File: doc.Path, Kind: doc.Kind, Err: err}
+	}
+	var spec Spec
+	if err := json.Unmarshal(doc.Data, &spec); err != nil {
+		return nil, &upgrade.DecodeError{File: doc.Path, Kind: doc.Kind, Err: err}
+	}
+	return append(violations, checkSpec(&spec)...), nil
+}
+
+// checkSpec returns the semantic violations of spec.
+func checkSpec(spec *Spec) []upgrade.Violation {
+	var violations []upgrade.Violation
+	add := func(field upgrade.Field, format string, args ...interface{}) {
+		violations = append(violations, upgrade.Violation{Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if caps := spec.Process.Capabilities.V; caps != nil {
+		for _, set := range []struct {
+			name string
+			caps []string
+		}{
+			{"bounding", caps.Bounding},
+			{"effective", caps.Effective},
+			{"inheritable", caps.Inheritable},
+			{"permitted", caps.Permitted},
+			{"ambient", caps.Ambient},
+		} {
+			for i, c := range set.caps {
+				if !capabilities[c] {
+					add(upgrade.Field{"process", "capabilities", set.name, i}, "unknown capability %s", c)
+				}
+			}
+		}
+	}
+
+	for i, m := range spec.Mounts {
+		if !path.IsAbs(m.Destination) {
+			add(upgrade.Field{"mounts", i, "destination"}, "%q is not an absolute path", m.Destination)
+		}
+		for j, o := range m.Options {
+			if reason := checkMountOption(o); reason != "" {
+				add(upgrade.Field{"mounts", i, "options", j}, "%q %s", o, reason)
+			}
+		}
+	}
+
+	if spec.Linux == nil {
+		return violations
+	}
+	seen := make(map[specs.LinuxNamespaceType]int)
+	for i, ns := range spec.Linux.Namespaces {
+		if j, ok := seen[ns.Type]; ok {
+			add(upgrade.Field{"linux", "namespaces", i}, "duplicate of the %s namespace %d", ns.Type, j)
+		}
+		seen[ns.Type] = i
+		if ns.Path != "" && !path.IsAbs(ns.Path) {
+			add(upgrade.Field{"linux", "namespaces", i, "path"}, "%q is not an absolute path", ns.Path)
+		}
+	}
+	if _, ok := seen[specs.UserNamespace]; ok && (len(spec.Linux.UIDMappings) == 0 || len(spec.Linux.GIDMappings) == 0) {
+		add(upgrade.Field{"linux", "namespaces", seen[specs.UserNamespace]}, "a user namespace requires uid and gid mappings")
+	}
+	if seccomp := spec.Linux.Seccomp; seccomp != nil {
+		for i, s := range seccomp.Syscalls {
+			for j, name := range s.Names {
+				if name == "" {
+					add(upgrade.Field{"linux", "seccomp", "syscalls", i, "names", j}, "empty syscall name")
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// checkMountOption returns why o is not a valid mount option, or "" if it is.
+// runc joins the options it does not turn into flags with commas.
+func checkMountOption(o string) string {
+	switch {
+	case o == "":
+		return "is empty"
+	case strings.ContainsAny(o, ", \t\n"):
+		return "contains a comma or a space"
+	case strings.HasPrefix(o, "="):
+		return "has no name"
+	}
+	return ""
+}
+
+// capabilities are the names of the capabilities known to runc.
+var capabilities = map[string]bool{
+	"CAP_CHOWN":            true,
+	"CAP_DAC_OVERRIDE":     true,
+	"CAP_DAC_READ_SEARCH":  true,
+	"CAP_FOWNER":           true,
+	"CAP_FSETID":           true,
+	"CAP_KILL":             true,
+	"CAP_SETGID":           true,
+	"CAP_SETUID":           true,
+	"CAP_SETPCAP":          true,
+	"CAP_LINUX_IMMUTABLE":  true,
+	"CAP_NET_BIND_SERVICE": true,
+	"CAP_NET_BROADCAST":    true,
+	"CAP_NET_ADMIN":        true,
+	"CAP_NET_RAW":          true,
+	"CAP_IPC_LOCK":         true,
+	"CAP_IPC_OWNER":        true,
+	"CAP_SYS_MODULE":       true,
+	"CAP_SYS_RAWIO":        true,
+	"CAP_SYS_CHROOT":       true,
+	"CAP_SYS_PTRACE":       true,
+	"CAP_SYS_PACCT":        true,
+	"CAP_SYS_ADMIN":        true,
+	"CAP_SYS_BOOT":         true,
+	"CAP_SYS_NICE":         true,
+	"CAP_SYS_RESOURCE":     true,
+	"CAP_SYS_TIME":         true,
+	"CAP_SYS_TTY_CONFIG":   true,
+	"CAP_MKNOD":            true,
+	"CAP_LEASE":            true,
+	"CAP_AUDIT_WRITE":      true,
+	"CAP_AUDIT_CONTROL":    true,
+	"CAP_SETFCAP":          true,
+	"CAP_MAC_OVERRIDE":     true,
+	"CAP_MAC_ADMIN":        true,
+	"CAP_SYSLOG":           true,
+	"CAP_WAKE_ALARM":       true,
+	"CAP_BLOCK_SUSPEND":    true,
+	"CAP_AUDIT_READ":       true,
+}
@@ -0,0 +1,857 @@
+// DO NOT EDIT
+// This file has been auto-generated with go generate.
+
+package v17_06_1
+
+var schemaFiles = map[string]string{
+	"config-linux.json": `{
+    "linux": {
+        "description": "Linux platform-specific configurations",
+        "type": "object",
+        "properties": {
+            "devices": {
+                "type": "array",
+                "items": {
+                    "$ref": "defs-linux.json#/definitions/Device"
+                }
+            },
+            "uidMappings": {
+                "type": "array",
+                "items": {
+                    "$ref": "defs.json#/definitions/IDMapping"
+                }
+            },
+            "gidMappings": {
+                "type": "array",
+                "items": {
+                    "$ref": "defs.json#/definitions/IDMapping"
+                }
+            },
+            "namespaces": {
+                "type": "array",
+                "items": {
+                    "anyOf": [
+                        {
+                            "$ref": "defs-linux.json#/definitions/NamespaceReference"
+                        }
+                    ]
+                }
+            },
+            "resources": {
+                "type": "object",
+                "properties": {
+                    "devices": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "defs-linux.json#/definitions/DeviceCgroup"
+                        }
+                    },
+                    "oomScoreAdj": {
+                        "type": "integer",
+                        "minimum": -1000,
+                        "maximum": 1000
+                    },
+                    "pids": {
+                        "type": "object",
+                        "properties": {
+                            "limit": {
+                                "$ref": "defs.json#/definitions/int64"
+                            }
+                        },
+                        "required": [
+                            "limit"
+                        ]
+                    },
+                    "blockIO": {
+                        "type": "object",
+                        "properties": {
+                            "weight": {
+                                "$ref": "defs-linux.json#/definitions/blkioWeight"
+                            },
+                            "leafWeight": {
+                                "$ref": "defs-linux.json#/definitions/blkioWeight"
+                            },
+                            "throttleReadBpsDevice": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "defs-linux.json#/definitions/blockIODeviceThrottle"
+                                }
+                            },
+                            "throttleWriteBpsDevice": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "defs-linux.json#/definitions/blockIODeviceThrottle"
+                                }
+                            },
+                            "throttleReadIOPSDevice": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "defs-linux.json#/definitions/blockIODeviceThrottle"
+                                }
+                            },
+                            "throttleWriteIOPSDevice": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "defs-linux.json#/definitions/blockIODeviceThrottle"
+                                }
+                            },
+                            "weightDevice": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "defs-linux.json#/definitions/blockIODeviceWeight"
+                                }
+                            }
+                        }
+                    },
+                    "cpu": {
+                        "type": "object",
+                        "properties": {
+                            "cpus": {
+                                "type": "string"
+                            },
+                            "mems": {
+                                "type": "string"
+                            },
+                            "period": {
+                                "$ref": "defs.json#/definitions/uint64"
+                            },
+                            "quota": {
+                                "$ref": "defs.json#/definitions/int64"
+                            },
+                            "realtimePeriod": {
+                                "$ref": "defs.json#/definitions/uint64"
+                            },
+                            "realtimeRuntime": {
+                                "$ref": "defs.json#/definitions/int64"
+                            },
+                            "shares": {
+                                "$ref": "defs.json#/definitions/uint64"
+                            }
+                        }
+                    },
+                    "disableOOMKiller": {
+                        "type": "boolean"
+                    },
+                    "hugepageLimits": {
+                        "type": "array",
+                        "items": {
+                            "type": "object",
+                            "properties": {
+                                "pageSize": {
+                                    "type": "string"
+                                },
+                                "limit": {
+                                    "$ref": "defs.json#/definitions/uint64"
+                                }
+                            },
+                            "required": [
+                                "pageSize",
+                                "limit"
+                            ]
+                        }
+                    },
+                    "memory": {
+                        "type": "object",
+                        "properties": {
+                            "kernel": {
+                                "$ref": "defs.json#/definitions/int64"
+                            },
+                            "kernelTCP": {
+                                "$ref": "defs.json#/definitions/int64"
+                            },
+                            "limit": {
+                                "$ref": "defs.json#/definitions/int64"
+                            },
+                            "reservation": {
+                                "$ref": "defs.json#/definitions/int64"
+                            },
+                            "swap": {
+                                "$ref": "defs.json#/definitions/int64"
+                            },
+                            "swappiness": {
+                                "$ref": "defs.json#/definitions/uint64"
+                            }
+                        }
+                    },
+                    "network": {
+                        "type": "object",
+                        "properties": {
+                            "classID": {
+                                "$ref": "defs.json#/definitions/uint32"
+                            },
+                            "priorities": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "defs-linux.json#/definitions/NetworkInterfacePriority"
+                                }
+                            }
+                        }
+                    }
+                }
+            },
+            "cgroupsPath": {
+                "type": "string"
+            },
+            "rootfsPropagation": {
+                "type": "string",
+                "enum": [
+                    "private",
+                    "shared",
+                    "slave",
+                    "unbindable"
+                ]
+            },
+            "seccomp": {
+                "type": "object",
+                "properties": {
+                    "defaultAction": {
+                        "$ref": "defs-linux.json#/definitions/SeccompAction"
+                    },
+                    "architectures": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "defs-linux.json#/definitions/SeccompArch"
+                        }
+                    },
+                    "syscalls": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "defs-linux.json#/definitions/Syscall"
+                        }
+                    }
+                },
+                "required": [
+                    "defaultAction"
+                ]
+            },
+            "sysctl": {
+                "$ref": "defs.json#/definitions/mapStringString"
+            },
+            "maskedPaths": {
+                "$ref": "defs.json#/definitions/ArrayOfStrings"
+            },
+            "readonlyPaths": {
+                "$ref": "defs.json#/definitions/ArrayOfStrings"
+            },
+            "mountLabel": {
+                "type": "string"
+            }
+        }
+    }
+}
+`,
+	"config-schema.json": `{
+    "description": "Open Container Runtime Specification Container Configuration Schema",
+    "$schema": "http://json-schema.org/draft-04/schema#",
+    "id": "https://opencontainers.org/schema/bundle",
+    "type": "object",
+    "properties": {
+        "ociVersion": {
+            "$ref": "defs.json#/definitions/ociVersion"
+        },
+        "hooks": {
+            "type": "object",
+            "properties": {
+                "prestart": {
+                    "$ref": "defs.json#/definitions/ArrayOfHooks"
+                },
+                "poststart": {
+                    "$ref": "defs.json#/definitions/ArrayOfHooks"
+                },
+                "poststop": {
+                    "$ref": "defs.json#/definitions/ArrayOfHooks"
+                }
+            }
+        },
+        "annotations": {
+            "$ref": "defs.json#/definitions/annotations"
+        },
+        "hostname": {
+            "type": "string"
+        },
+        "mounts": {
+            "type": "array",
+            "items": {
+                "$ref": "defs.json#/definitions/Mount"
+            }
+        },
+        "platform": {
+            "type": "object",
+            "required": [
+                "arch",
+                "os"
+            ],
+            "properties": {
+                "arch": {
+                    "type": "string"
+                },
+                "os": {
+                    "type": "string"
+                }
+            }
+        },
+        "root": {
+            "description": "Configures the container's root filesystem.",
+            "type": "object",
+            "required": [
+                "path"
+            ],
+            "properties": {
+                "path": {
+                    "$ref": "defs.json#/definitions/FilePath"
+                },
+                "readonly": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "process": {
+            "type": "object",
+            "required": [
+                "cwd",
+                "args"
+            ],
+            "properties": {
+                "args": {
+                    "$ref": "defs.json#/definitions/ArrayOfStrings"
+                },
+                "consoleSize": {
+                    "type": "object",
+                    "required": [
+                        "height",
+                        "width"
+                    ],
+                    "properties": {
+                        "height": {
+                            "$ref": "defs.json#/definitions/uint64"
+                        },
+                        "width": {
+                            "$ref": "defs.json#/definitions/uint64"
+                        }
+                    }
+                },
+                "cwd": {
+                    "type": "string"
+                },
+                "env": {
+                    "$ref": "defs.json#/definitions/Env"
+                },
+                "terminal": {
+                    "type": "boolean"
+                },
+                "user": {
+                    "type": "object",
+                    "properties": {
+                        "uid": {
+                            "$ref": "defs.json#/definitions/UID"
+                        },
+                        "gid": {
+                            "$ref": "defs.json#/definitions/GID"
+                        },
+                        "additionalGids": {
+                            "$ref": "defs.json#/definitions/ArrayOfGIDs"
+                        },
+                        "username": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "capabilities": {
+                    "type": "object",
+                    "properties": {
+                        "bounding": {
+                            "$ref": "defs.json#/definitions/ArrayOfStrings"
+                        },
+                        "permitted": {
+                            "$ref": "defs.json#/definitions/ArrayOfStrings"
+                        },
+                        "effective": {
+                            "$ref": "defs.json#/definitions/ArrayOfStrings"
+                        },
+                        "inheritable": {
+                            "$ref": "defs.json#/definitions/ArrayOfStrings"
+                        },
+                        "ambient": {
+                            "$ref": "defs.json#/definitions/ArrayOfStrings"
+                        }
+                    }
+                },
+                "apparmorProfile": {
+                    "type": "string"
+                },
+                "selinuxLabel": {
+                    "type": "string"
+                },
+                "noNewPrivileges": {
+                    "type": "boolean"
+                },
+                "rlimits": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "required": [
+                            "type",
+                            "soft",
+                            "hard"
+                        ],
+                        "properties": {
+                            "hard": {
+                                "$ref": "defs.json#/definitions/uint64"
+                            },
+                            "soft": {
+                                "$ref": "defs.json#/definitions/uint64"
+                            },
+                            "type": {
+                                "type": "string",
+                                "pattern": "^RLIMIT_[A-Z]+$"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "linux": {
+            "$ref": "config-linux.json#/linux"
+        }
+    },
+    "required": [
+        "ociVersion",
+        "root"
+    ]
+}
+`,
+	"defs-linux.json": `{
+    "definitions": {
+        "SeccompArch": {
+            "type": "string",
+            "enum": [
+                "SCMP_ARCH_X86",
+                "SCMP_ARCH_X86_64",
+                "SCMP_ARCH_X32",
+                "SCMP_ARCH_ARM",
+                "SCMP_ARCH_AARCH64",
+                "SCMP_ARCH_MIPS",
+                "SCMP_ARCH_MIPS64",
+                "SCMP_ARCH_MIPS64N32",
+                "SCMP_ARCH_MIPSEL",
+                "SCMP_ARCH_MIPSEL64",
+                "SCMP_ARCH_MIPSEL64N32",
+                "SCMP_ARCH_PPC",
+                "SCMP_ARCH_PPC64",
+                "SCMP_ARCH_PPC64LE",
+                "SCMP_ARCH_S390",
+                "SCMP_ARCH_S390X",
+                "SCMP_ARCH_PARISC",
+                "SCMP_ARCH_PARISC64"
+            ]
+        },
+        "SeccompAction": {
+            "type": "string",
+            "enum": [
+                "SCMP_ACT_KILL",
+                "SCMP_ACT_TRAP",
+                "SCMP_ACT_ERRNO",
+                "SCMP_ACT_TRACE",
+                "SCMP_ACT_ALLOW"
+            ]
+        },
+        "SeccompOperators": {
+            "type": "string",
+            "enum": [
+                "SCMP_CMP_NE",
+                "SCMP_CMP_LT",
+                "SCMP_CMP_LE",
+                "SCMP_CMP_EQ",
+                "SCMP_CMP_GE",
+                "SCMP_CMP_GT",
+                "SCMP_CMP_MASKED_EQ"
+            ]
+        },
+        "SyscallArg": {
+            "type": "object",
+            "properties": {
+                "index": {
+                    "$ref": "defs.json#/definitions/uint32"
+                },
+                "value": {
+                    "$ref": "defs.json#/definitions/uint64"
+                },
+                "valueTwo": {
+                    "$ref": "defs.json#/definitions/uint64"
+                },
+                "op": {
+                    "$ref": "#/definitions/SeccompOperators"
+                }
+            },
+            "required": [
+                "index",
+                "value",
+                "op"
+            ]
+        },
+        "Syscall": {
+            "type": "object",
+            "properties": {
+                "names": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "minItems": 1
+                },
+                "action": {
+                    "$ref": "#/definitions/SeccompAction"
+                },
+                "args": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/SyscallArg"
+                    }
+                }
+            },
+            "required": [
+                "names",
+                "action"
+            ]
+        },
+        "Major": {
+            "description": "major device number",
+            "$ref": "defs.json#/definitions/int64"
+        },
+        "Minor": {
+            "description": "minor device number",
+            "$ref": "defs.json#/definitions/int64"
+        },
+        "FileMode": {
+            "description": "File permissions mode (typically an octal value)",
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 512
+        },
+        "FileType": {
+            "description": "Type of a block or special character device",
+            "type": "string",
+            "pattern": "^[cbup]$"
+        },
+        "Device": {
+            "type": "object",
+            "required": [
+                "type",
+                "path"
+            ],
+            "properties": {
+                "type": {
+                    "$ref": "#/definitions/FileType"
+                },
+                "path": {
+                    "$ref": "defs.json#/definitions/FilePath"
+                },
+                "fileMode": {
+                    "$ref": "#/definitions/FileMode"
+                },
+                "major": {
+                    "$ref": "#/definitions/Major"
+                },
+                "minor": {
+                    "$ref": "#/definitions/Minor"
+                },
+                "uid": {
+                    "$ref": "defs.json#/definitions/UID"
+                },
+                "gid": {
+                    "$ref": "defs.json#/definitions/GID"
+                }
+            }
+        },
+        "weight": {
+            "$ref": "defs.json#/definitions/uint16"
+        },
+        "blkioWeight": {
+            "type": "integer",
+            "anyOf": [
+                {
+                    "enum": [
+                        0
+                    ]
+                },
+                {
+                    "minimum": 10,
+                    "maximum": 1000
+                }
+            ]
+        },
+        "blockIODevice": {
+            "type": "object",
+            "properties": {
+                "major": {
+                    "$ref": "#/definitions/Major"
+                },
+                "minor": {
+                    "$ref": "#/definitions/Minor"
+                }
+            },
+            "required": [
+                "major",
+                "minor"
+            ]
+        },
+        "blockIODeviceWeight": {
+            "type": "object",
+            "allOf": [
+                {
+                    "$ref": "#/definitions/blockIODevice"
+                },
+                {
+                    "type": "object",
+                    "properties": {
+                        "weight": {
+                            "$ref": "#/definitions/weight"
+                        },
+                        "leafWeight": {
+                            "$ref": "#/definitions/weight"
+                        }
+                    }
+                }
+            ]
+        },
+        "blockIODeviceThrottle": {
+            "allOf": [
+                {
+                    "$ref": "#/definitions/blockIODevice"
+                },
+                {
+                    "type": "object",
+                    "properties": {
+                        "rate": {
+                            "$ref": "defs.json#/definitions/uint64"
+                        }
+                    }
+                }
+            ]
+        },
+        "DeviceCgroup": {
+            "type": "object",
+            "properties": {
+                "allow": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "major": {
+                    "$ref": "#/definitions/Major"
+                },
+                "minor": {
+                    "$ref": "#/definitions/Minor"
+                },
+                "access": {
+                    "type": "string"
+                }
+            },
+            "required": [
+                "allow"
+            ]
+        },
+        "NetworkInterfacePriority": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "priority": {
+                    "$ref": "defs.json#/definitions/uint32"
+                }
+            },
+            "required": [
+                "name",
+                "priority"
+            ]
+        },
+        "NamespaceType": {
+            "type": "string",
+            "enum": [
+                "mount",
+                "pid",
+                "network",
+                "uts",
+                "ipc",
+                "user",
+                "cgroup"
+            ]
+        },
+        "NamespaceReference": {
+            "type": "object",
+            "properties": {
+                "type": {
+                    "$ref": "#/definitions/NamespaceType"
+                },
+                "path": {
+                    "$ref": "defs.json#/definitions/FilePath"
+                }
+            },
+            "required": [
+                "type"
+            ]
+        }
+    }
+}
+`,
+	"defs.json": `{
+    "description": "Definitions used throughout the Open Container Runtime Specification",
+    "definitions": {
+        "int8": {
+            "type": "integer",
+            "minimum": -128,
+            "maximum": 127
+        },
+        "int16": {
+            "type": "integer",
+            "minimum": -32768,
+            "maximum": 32767
+        },
+        "int32": {
+            "type": "integer",
+            "minimum": -2147483648,
+            "maximum": 2147483647
+        },
+        "int64": {
+            "type": "integer",
+            "minimum": -9223372036854775808,
+            "maximum": 9223372036854775807
+        },
+        "uint8": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 255
+        },
+        "uint16": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 65535
+        },
+        "uint32": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 4294967295
+        },
+        "uint64": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 18446744073709551615
+        },
+        "percent": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 100
+        },
+        "mapStringString": {
+            "type": "object",
+            "patternProperties": {
+                ".{1,}": {
+                    "type": "string"
+                }
+            }
+        },
+        "UID": {
+            "$ref": "#/definitions/uint32"
+        },
+        "GID": {
+            "$ref": "#/definitions/uint32"
+        },
+        "ArrayOfGIDs": {
+            "type": "array",
+            "items": {
+                "$ref": "#/definitions/GID"
+            }
+        },
+        "ArrayOfStrings": {
+            "type": "array",
+            "items": {
+                "type": "string"
+            }
+        },
+        "FilePath": {
+            "type": "string"
+        },
+        "Env": {
+            "$ref": "#/definitions/ArrayOfStrings"
+        },
+        "Hook": {
+            "type": "object",
+            "properties": {
+                "path": {
+                    "$ref": "#/definitions/FilePath"
+                },
+                "args": {
+                    "$ref": "#/definitions/ArrayOfStrings"
+                },
+                "env": {
+                    "$ref": "#/definitions/Env"
+                },
+                "timeout": {
+                    "type": "integer",
+                    "minimum": 1
+                }
+            },
+            "required": [
+                "path"
+            ]
+        },
+        "ArrayOfHooks": {
+            "type": "array",
+            "items": {
+                "$ref": "#/definitions/Hook"
+            }
+        },
+        "IDMapping": {
+            "type": "object",
+            "properties": {
+                "hostID": {
+                    "$ref": "#/definitions/uint32"
+                },
+                "containerID": {
+                    "$ref": "#/definitions/uint32"
+                },
+                "size": {
+                    "$ref": "#/definitions/uint32"
+                }
+            },
+            "required": [
+                "hostID",
+                "containerID",
+                "size"
+            ]
+        },
+        "Mount": {
+            "type": "object",
+            "properties": {
+                "source": {
+                    "$ref": "#/definitions/FilePath"
+                },
+                "destination": {
+                    "$ref": "#/definitions/FilePath"
+                },
+                "options": {
+                    "$ref": "#/definitions/ArrayOfStrings"
+                },
+                "type": {
+                    "type": "string"
+                }
+            },
+            "required": [
+                "destination",
+                "source",
+                "type"
+            ]
+        },
+        "ociVersion": {
+            "description": "The version of Open Container Runtime Specification that the document complies with",
+            "type": "string"
+        },
+        "annotations": {
+            "$ref": "#/definitions/mapStringString"
+        }
+    }
+}
+`,
+}
@@ -0,0 +1,95 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+func TestValidateDocument(t *testing.T) {
+	for _, version := range []string{"17.03", "17.05", "17.06.0", "17.06.1"} {
+		c, _ := fixtureContainer(t, version, nil)
+		report, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, DryRun: true})
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		for _, f := range report.Files {
+			if len(f.Violations) != 0 {
+				t.Fatalf("%s: %s: %v", version, f.Path, f.Violations)
+			}
+		}
+	}
+	// the config written by Docker itself is valid.
+	c, fs := fixtureContainer(t, "17.06.1", nil)
+	doc := &upgrade.Document{Path: c.Config, Kind: upgrade.KindConfig}
+	var err error
+	if doc.Data, err = readFile(fs, c.Config); err != nil {
+		t.Fatal(err)
+	}
+	if violations, err := upgrade.ValidateDocument(doc, Version); err != nil || len(violations) != 0 {
+		t.Fatalf("%v, %v", violations, err)
+	}
+
+	for _, d := range [...]struct {
+		name     string
+		old, new string
+		// violation is the first of the n violations of the converted
+		// config.
+		violation string
+		n         int
+	}{
+		{"capability", `"CAP_KILL"`, `"CAP_KILLALL"`, ".process.capabilities.bounding[12]: unknown capability CAP_KILLALL", 4},
+		{"namespace type", `{"type":"uts"}`, `{"type":"time"}`, `.linux.namespaces[2].type: "time" is not one of`, 1},
+		{"duplicate namespace", `{"type":"uts"}`, `{"type":"mount"}`, ".linux.namespaces[2]: duplicate of the mount namespace 0", 1},
+		{"seccomp action", `"defaultAction":"SCMP_ACT_ERRNO"`, `"defaultAction":"SCMP_ACT_LOG"`, `.linux.seccomp.defaultAction: "SCMP_ACT_LOG" is not one of`, 1},
+		{"mount option", `"mode=755"`, `"mode=755,size=64k"`, `.mounts[1].options[2]: "mode=755,size=64k" contains a comma or a space`, 1},
+		{"mount destination", `"destination":"/dev/mqueue"`, `"destination":"dev/mqueue"`, `.mounts[5].destination: "dev/mqueue" is not an absolute path`, 1},
+	} {
+		edit := func(kind upgrade.Kind, b []byte) []byte {
+			if kind != upgrade.KindConfig {
+				return b
+			}
+			return bytes.Replace(b, []byte(d.old), []byte(d.new), -1)
+		}
+		c, fs := fixtureContainer(t, "17.03", edit)
+		original, err := readFile(fs, c.Config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, SkipValidation: true})
+		var e *upgrade.InvalidError
+		if !errors.As(err, &e) || e.File != c.Config || e.Kind != upgrade.KindConfig {
+			t.Fatalf("%s: unexpected error: %v", d.name, err)
+		}
+		if len(e.Violations) != d.n || !strings.HasPrefix(e.Violations[0].String(), d.violation) {
+			t.Fatalf("%s: unexpected violations: %v", d.name, e.Violations)
+		}
+		reported := 0
+		if report != nil {
+			for _, f := range report.Files {
+				reported += len(f.Violations)
+			}
+		}
+		if reported != d.n {
+			t.Fatalf("%s: violations not reported: %+v", d.name, report)
+		}
+		// nothing is written.
+		if b, err := readFile(fs, c.Config); err != nil || !bytes.Equal(b, original) {
+			t.Fatalf("%s: config written: %v", d.name, err)
+		}
+		if backups, err := upgrade.ListBackups(c); err != nil || len(backups) != 0 {
+			t.Fatalf("%s: backed up: %v, %v", d.name, backups, err)
+		}
+
+		if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, SkipValidation: true, AllowInvalid: true}); err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		if b, err := readFile(fs, c.Config); err != nil || bytes.Equal(b, original) {
+			t.Fatalf("%s: config not written: %v", d.name, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/crosbymichael/upgrade"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Normalize compacts the seccomp rules of a config; see compact. The other
+// documents are left as is.
+func (u upgrader) Normalize(doc *upgrade.Document) error {
+	if doc.Kind != upgrade.KindConfig {
+		return nil
+	}
+	var spec Spec
+	if err := json.Unmarshal(doc.Data, &spec); err != nil {
+		return &upgrade.DecodeError{Err: err}
+	}
+	if spec.Linux == nil || spec.Linux.Seccomp == nil {
+		return nil
+	}
+	spec.Linux.Seccomp.Syscalls = spec.Linux.Seccomp.Syscalls.compact()
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(&spec); err != nil {
+		return err
+	}
+	doc.Data = buf.Bytes()
+	return nil
+}
+
+// compact merges the rules with the same action and arguments into one, with
+// their syscall names sorted and deduplicated, and sorts the rules by action
+// then arguments. Rules without names are dropped.
+//
+// runc adds a filter for every name of every rule, and libseccomp does not
+// depend on the order of the filters: the compacted rules add the same
+// filters, as shown by filters.
+func (s linuxSyscalls) compact() linuxSyscalls {
+	var rules linuxSyscalls
+	for _, r := range s {
+		i := 0
+		for ; i < len(rules); i++ {
+			if rules[i].Action == r.Action && sameArgs(rules[i].Args, r.Args) {
+				break
+			}
+		}
+		if i == len(rules) {
+			rules = append(rules, linuxSyscall{specs.LinuxSyscall{Action: r.Action, Args: r.Args}})
+		}
+		rules[i].Names = append(rules[i].Names, r.Names...)
+	}
+
+	compacted := rules[:0]
+	for _, r := range rules {
+		r.Names = uniqueStrings(r.Names)
+		if len(r.Args) == 0 {
+			r.Args = nil
+		}
+		if len(r.Names) > 0 {
+			compacted = append(compacted, r)
+		}
+	}
+	sort.SliceStable(compacted, func(i, j int) bool {
+		if compacted[i].Action != compacted[j].Action {
+			return compacted[i].Action < compacted[j].Action
+		}
+		return argsKey(compacted[i].Args) < argsKey(compacted[j].Args)
+	})
+	if len(compacted) == 0 {
+		return s[:0]
+	}
+	return compacted
+}
+
+// filters returns, for every syscall named by s, the filters runc adds for
+// it: an action and the conditions on its arguments, sorted.
+func (s linuxSyscalls) filters() map[string][]string {
+	filters := make(map[string][]string)
+	for _, r := range s {
+		filter := fmt.Sprintf("%s %s", r.Action, argsKey(r.Args))
+		for _, name := range r.Names {
+			filters[name] = append(filters[name], filter)
+		}
+	}
+	for name, f := range filters {
+		filters[name] = uniqueStrings(f)
+	}
+	return filters
+}
+
+func sameArgs(a, b []specs.LinuxSeccompArg) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
+
+// argsKey returns a representation of args that sorts the rules without
+// arguments first.
+func argsKey(args []specs.LinuxSeccompArg) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v", args)
+}
+
+// uniqueStrings returns s sorted, without duplicates.
+func uniqueStrings(s []string) []string {
+	sort.Strings(s)
+	unique := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,138 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+func TestCompactSeccompFixtures(t *testing.T) {
+	for _, v := range []string{"17.03", "17.05", "17.06.0", "17.06.1"} {
+		name := filepath.Join("..", "testfiles", "config.json-"+v)
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var spec Spec
+		if err := json.Unmarshal(b, &spec); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		rules := spec.Linux.Seccomp.Syscalls
+		compacted := rules.compact()
+		if !reflect.DeepEqual(rules.filters(), compacted.filters()) {
+			t.Fatalf("%s: the filters differ", name)
+		}
+		if len(compacted) >= len(rules)/10 {
+			t.Fatalf("%s: %d rules compacted to %d", name, len(rules), len(compacted))
+		}
+		for i, r := range compacted {
+			if !sort.StringsAreSorted(r.Names) {
+				t.Fatalf("%s: rule %d: names not sorted: %v", name, i, r.Names)
+			}
+		}
+		if again := compacted.compact(); !reflect.DeepEqual(again, compacted) {
+			t.Fatalf("%s: compact is not idempotent", name)
+		}
+	}
+}
+
+func TestCompactSeccomp(t *testing.T) {
+	for _, d := range [...]struct {
+		name          string
+		rules, expect string
+	}{
+		{"empty", `[]`, `[]`},
+		{
+			"merged and sorted",
+			`[{"names":["b"],"action":"SCMP_ACT_ALLOW"},{"names":["a"],"action":"SCMP_ACT_ERRNO"},{"names":["a","c"],"action":"SCMP_ACT_ALLOW","args":[]}]`,
+			`[{"names":["a","b","c"],"action":"SCMP_ACT_ALLOW"},{"names":["a"],"action":"SCMP_ACT_ERRNO"}]`,
+		},
+		{
+			"duplicates",
+			`[{"names":["alarm"],"action":"SCMP_ACT_ALLOW"},{"names":["alarm"],"action":"SCMP_ACT_ALLOW"}]`,
+			`[{"names":["alarm"],"action":"SCMP_ACT_ALLOW"}]`,
+		},
+		{
+			"args",
+			`[{"names":["socket"],"action":"SCMP_ACT_ALLOW","args":[{"index":0,"value":2,"valueTwo":0,"op":"SCMP_CMP_EQ"}]},{"names":["socket"],"action":"SCMP_ACT_ALLOW","args":[{"index":0,"value":1,"valueTwo":0,"op":"SCMP_CMP_EQ"}]},{"names":["bind"],"action":"SCMP_ACT_ALLOW","args":[{"index":0,"value":1,"valueTwo":0,"op":"SCMP_CMP_EQ"}]},{"names":["accept"],"action":"SCMP_ACT_ALLOW"}]`,
+			`[{"names":["accept"],"action":"SCMP_ACT_ALLOW"},{"names":["bind","socket"],"action":"SCMP_ACT_ALLOW","args":[{"index":0,"value":1,"valueTwo":0,"op":"SCMP_CMP_EQ"}]},{"names":["socket"],"action":"SCMP_ACT_ALLOW","args":[{"index":0,"value":2,"valueTwo":0,"op":"SCMP_CMP_EQ"}]}]`,
+		},
+		{
+			"no names",
+			`[{"names":[],"action":"SCMP_ACT_KILL"},{"name":"read","action":"SCMP_ACT_ALLOW"}]`,
+			`[{"names":["read"],"action":"SCMP_ACT_ALLOW"}]`,
+		},
+	} {
+		var rules linuxSyscalls
+		if err := json.Unmarshal([]byte(d.rules), &rules); err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		compacted := rules.compact()
+		b, err := json.Marshal(compacted)
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		if string(b) != d.expect {
+			t.Fatalf("%s: expected %s, got %s", d.name, d.expect, b)
+		}
+		if !reflect.DeepEqual(rules.filters(), compacted.filters()) {
+			t.Fatalf("%s: the filters differ: %v | %v", d.name, rules.filters(), compacted.filters())
+		}
+	}
+}
+
+func TestUpgradeNormalize(t *testing.T) {
+	for _, normalize := range []bool{false, true} {
+		c, fs := fixtureContainer(t, "17.03", nil)
+		if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, Normalize: normalize}); err != nil {
+			t.Fatalf("normalize %v: %v", normalize, err)
+		}
+		b, err := readFile(fs, c.Config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var spec Spec
+		if err := json.Unmarshal(b, &spec); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(spec.Linux.Seccomp.Syscalls); normalize != (n < 30) {
+			t.Fatalf("normalize %v: %d rules", normalize, n)
+		}
+	}
+}
+
+// TestNormalizePreserveUnknown checks that the unknown fields of the seccomp
+// rules are not preserved on other rules once these are compacted.
+func TestNormalizePreserveUnknown(t *testing.T) {
+	for _, normalize := range []bool{false, true} {
+		c, fs := fixtureContainer(t, "17.06.0", func(kind upgrade.Kind, b []byte) []byte {
+			return bytes.Replace(b, []byte(`{"names":["accept"],`), []byte(`{"fork":1,"names":["accept"],`), 1)
+		})
+		_, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, Normalize: normalize, PreserveUnknown: true})
+		if !normalize {
+			if err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		var e *upgrade.ConvertError
+		if !errors.As(err, &e) || !strings.Contains(err.Error(), ".linux.seccomp.syscalls[") {
+			t.Fatalf("expected a conversion error, got %v", err)
+		}
+		b, err := readFile(fs, c.Config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(b, []byte(`"comment"`)) {
+			t.Fatal("the config was upgraded")
+		}
+	}
+}
@@ -10,7 +10,7 @@ type Spec struct {
 	Platform specs.Platform `json:"platform"`
 	Process  struct {
 		Terminal        bool                `json:"terminal,omitempty"`
-		ConsoleSize     specs.Box           `json:"consoleSize,omitempty"`
+		ConsoleSize     *specs.Box          `json:"consoleSize,omitempty"`
 		User            specs.User          `json:"user"`
 		Args            []string            `json:"args"`
 		Env             []string            `json:"env,omitempty"`
@@ -35,16 +35,16 @@ type Spec struct {
 			DisableOOMKiller *bool                     `json:"disableOOMKiller,omitempty"`
 			OOMScoreAdj      *int                      `json:"oomScoreAdj,omitempty"`
 			Memory           *struct {
-				Limit       *int64           `json:"limit,omitempty"`
-				Reservation *int64           `json:"reservation,omitempty"`
-				Swap        *int64           `json:"swap,omitempty"`
-				Kernel      *int64           `json:"kernel,omitempty"`
-				KernelTCP   *int64           `json:"kernelTCP,omitempty"`
-				Swappiness  memorySwappiness `json:"swappiness,omitempty"`
+				Limit       *int64            `json:"limit,omitempty"`
+				Reservation *int64            `json:"reservation,omitempty"`
+				Swap        *int64            `json:"swap,omitempty"`
+				Kernel      *int64            `json:"kernel,omitempty"`
+				KernelTCP   *int64            `json:"kernelTCP,omitempty"`
+				Swappiness  *MemorySwappiness `json:"swappiness,omitempty"`
 			} `json:"memory,omitempty"`
 			CPU            *specs.LinuxCPU            `json:"cpu,omitempty"`
 			Pids           *specs.LinuxPids           `json:"pids,omitempty"`
-			BlockIO        *specs.LinuxBlockIO        `json:"blockIO,omitempty"`
+			BlockIO        *linuxBlockIO              `json:"blockIO,omitempty"`
 			HugepageLimits []specs.LinuxHugepageLimit `json:"hugepageLimits,omitempty"`
 			Network        *specs.LinuxNetwork        `json:"network,omitempty"`
 		} `json:"resources,omitempty"`
@@ -10,10 +10,10 @@ import (
 )
 
 type State struct {
-	ID                   string    `json:"id"`
-	InitProcessPid       int       `json:"init_process_pid"`
-	InitProcessStartTime string    `json:"init_process_start"`
-	Created              time.Time `json:"created"`
+	ID                   string               `json:"id"`
+	InitProcessPid       int                  `json:"init_process_pid"`
+	InitProcessStartTime InitProcessStartTime `json:"init_process_start"`
+	Created              time.Time            `json:"created"`
 	Config               struct {
 		NoPivotRoot       bool               `json:"no_pivot_root"`
 		ParentDeathSignal int                `json:"parent_death_signal"`
@@ -61,7 +61,7 @@ type State struct {
 			Freezer                      configs.FreezerState      `json:"freezer"`
 			HugetlbLimit                 []*configs.HugepageLimit  `json:"hugetlb_limit"`
 			OomKillDisable               bool                      `json:"oom_kill_disable"`
-			MemorySwappiness             memorySwappiness          `json:"memory_swappiness"`
+			MemorySwappiness             CgroupSwappiness          `json:"memory_swappiness"`
 			NetPrioIfpriomap             []*configs.IfPrioMap      `json:"net_prio_ifpriomap"`
 			NetClsClassid                uint32                    `json:"net_cls_classid_u"`
 		} `json:"cgroups"`
@@ -0,0 +1,62 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+func TestUpgradeStream(t *testing.T) {
+	for _, d := range [...]struct {
+		name    string
+		kind    upgrade.Kind
+		from    upgrade.Version
+		changed bool
+	}{
+		{"config.json-17.03", upgrade.KindConfig, upgrade.V17_03, true},
+		{"state.json-17.06.0", upgrade.KindState, upgrade.V17_06_0, true},
+		// the empty console size is dropped, as by 17.06.1.
+		{"process.json-17.03", upgrade.KindProcess, upgrade.V17_05, true},
+		{"process.json-17.06.0", upgrade.KindProcess, upgrade.V17_06_0, true},
+		{"process.json-17.06.1", upgrade.KindProcess, upgrade.V17_06_1, false},
+	} {
+		b, err := ioutil.ReadFile(filepath.Join("..", "testfiles", d.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		report, err := upgrade.UpgradeStream(d.kind, bytes.NewReader(b), &out, upgrade.Options{Target: Version})
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		if !d.changed {
+			if report != nil || !bytes.Equal(out.Bytes(), b) {
+				t.Fatalf("%s: expected an unchanged copy, got %+v", d.name, report)
+			}
+			continue
+		}
+		if report == nil || report.From != d.from || report.To != Version || len(report.Diff) == 0 {
+			t.Fatalf("%s: report %+v", d.name, report)
+		}
+		if v, _, evidence := upgrade.DetectVersion(d.kind, out.Bytes()); v != Version {
+			t.Fatalf("%s: upgraded to %s, evidence: %v", d.name, v, evidence)
+		}
+
+		out.Reset()
+		if _, err := upgrade.UpgradeStream(d.kind, bytes.NewReader(b), &out, upgrade.Options{Target: Version, DryRun: true}); err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		if out.Len() != 0 {
+			t.Fatalf("%s: dry run wrote %d bytes", d.name, out.Len())
+		}
+	}
+
+	_, err := upgrade.UpgradeStream(upgrade.KindConfig, strings.NewReader(`{}`), ioutil.Discard, upgrade.Options{Target: Version})
+	if err == nil || !strings.Contains(err.Error(), "the config document") {
+		t.Fatalf("expected an error naming the document, got %v", err)
+	}
+}
@@ -10,11 +10,11 @@ import (
 
 //go:generate -command rewrite go run ../gen/rewrite-structs.go --
 
-//go:generate rewrite spec_gen.go .Process.Capabilities->linuxCapabilities .Linux.Resources.Memory.Swappiness->memorySwappiness .Linux.Seccomp.Syscalls->linuxSyscalls
+//go:generate rewrite spec_gen.go .Process.ConsoleSize->*specs.Box .Process.Capabilities->linuxCapabilities .Linux.Resources.Memory.Swappiness->*MemorySwappiness .Linux.Seccomp.Syscalls->linuxSyscalls .Linux.Resources.BlockIO->*linuxBlockIO
 type Spec specs.Spec
 
-//go:generate rewrite process_state_gen.go .Capabilities->linuxCapabilities
+//go:generate rewrite process_state_gen.go .ConsoleSize->*specs.Box .Capabilities->linuxCapabilities
 type ProcessState runtime.ProcessState
 
-//go:generate rewrite state_gen.go .Config.Capabilities->linuxCapabilities .Config.Cgroups.MemorySwappiness->memorySwappiness
+//go:generate rewrite state_gen.go .InitProcessStartTime->InitProcessStartTime .Config.Capabilities->linuxCapabilities .Config.Cgroups.MemorySwappiness->CgroupSwappiness
 type State libcontainer.State
@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 
+	"github.com/crosbymichael/upgrade"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
@@ -14,75 +18,169 @@ type linuxSyscall struct {
 	specs.LinuxSyscall
 }
 
-func (ls *linuxSyscall) UnmarshalJSON(b []byte) error {
-	var t struct {
-		specs.LinuxSyscall
-		Name *string `json:"name,omitempty"`
+// LegacySyscall holds the fields of a seccomp rule that runtime-spec
+// v1.0.0-rc5 removed: the single syscall named before, and the empty comment
+// written by Docker 17.06.0, which is dropped.
+type LegacySyscall struct {
+	Name    *string `json:"name,omitempty"`
+	Comment string  `json:"comment,omitempty"`
+}
+
+// Merge returns the names of the syscalls of a rule with the given names and
+// the fields of l.
+func (l LegacySyscall) Merge(names []string) ([]string, error) {
+	if l.Name == nil {
+		return names, nil
+	}
+	if names != nil {
+		return nil, fmt.Errorf("found incompatible 'name' and 'names' fields")
 	}
+	return []string{*l.Name}, nil
+}
+
+// linuxSyscallJSON is a seccomp rule as written before runtime-spec
+// v1.0.0-rc5, where it names a single syscall, or after.
+type linuxSyscallJSON struct {
+	specs.LinuxSyscall
+	LegacySyscall
+}
+
+func (ls *linuxSyscall) Decoded() interface{} {
+	return &linuxSyscallJSON{}
+}
+
+func (ls *linuxSyscall) UnmarshalJSON(b []byte) error {
+	var t linuxSyscallJSON
 	if err := json.Unmarshal(b, &t); err != nil {
 		return err
 	}
+	names, err := t.LegacySyscall.Merge(t.LinuxSyscall.Names)
+	if err != nil {
+		return err
+	}
 	ls.LinuxSyscall = t.LinuxSyscall
-	if t.Name != nil {
-		if ls.LinuxSyscall.Names != nil {
-			return fmt.Errorf("found incompatible 'name' and 'names' fields")
+	ls.LinuxSyscall.Names = names
+	return nil
+}
+
+// InitProcessStartTime is the start time of the init process, in clock
+// ticks since boot. runc encodes it as a number since Docker 17.06.1, and as
+// a string before.
+type InitProcessStartTime uint64
+
+func (t InitProcessStartTime) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(t), 10)), nil
+}
+
+func (t *InitProcessStartTime) UnmarshalJSON(b []byte) error {
+	if bytes.Compare(b, null) == 0 {
+		return nil
+	}
+	s := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
 		}
-		ls.LinuxSyscall.Names = []string{*t.Name}
-		t.Name = nil
 	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid init process start time %s: expected a number of clock ticks", b)
+	}
+	*t = InitProcessStartTime(n)
 	return nil
 }
 
-// TODO: figure out how to omitempty when pointer is nil
-type memorySwappiness struct {
-	V *uint64 `json:",omitempty"`
+// swappinessDefault is the swappiness leaving the memory cgroup to the default
+// of the kernel. It is written -1, or 18446744073709551615 once -1 has gone
+// through an unsigned integer.
+const swappinessDefault = math.MaxUint64
+
+// MemorySwappiness is the swappiness of the memory cgroup. V is nil if the
+// swappiness is unset, points to swappinessDefault if it is explicitly left
+// to the kernel, and to a value between 0 and 100 otherwise. It is encoded the
+// way Docker writes it in config.json, where the field is omitted if unset;
+// see CgroupSwappiness for the runc state.
+type MemorySwappiness struct {
+	V *uint64
 }
 
-func (m memorySwappiness) String() string {
-	if m.V == nil {
+func (m MemorySwappiness) String() string {
+	switch {
+	case m.V == nil:
 		return "<nil>"
+	case *m.V == swappinessDefault:
+		return "-1"
+	}
+	return strconv.FormatUint(*m.V, 10)
+}
+
+// Compare orders swappiness settings by their effect, -1 if m leaves less
+// memory to swap than o: an unset swappiness and the default of the kernel
+// are the same.
+func (m MemorySwappiness) Compare(o MemorySwappiness) int {
+	effect := func(m MemorySwappiness) int64 {
+		if m.V == nil || *m.V == swappinessDefault {
+			return -1
+		}
+		return int64(*m.V)
+	}
+	switch a, b := effect(m), effect(o); {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
 	}
-	return fmt.Sprintf("%d", *m.V)
+	return 0
 }
 
 var null = []byte("null")
 
-func (m *memorySwappiness) MarshalJSON() ([]byte, error) {
+func (m MemorySwappiness) MarshalJSON() ([]byte, error) {
 	if m.V == nil {
 		return null, nil
 	}
-	return []byte(fmt.Sprintf("%d", *m.V)), nil
+	return []byte(strconv.FormatUint(*m.V, 10)), nil
 }
 
-func (m *memorySwappiness) UnmarshalJSON(b []byte) error {
+func (m *MemorySwappiness) UnmarshalJSON(b []byte) error {
 	if bytes.Compare(b, null) == 0 {
+		m.V = nil
 		return nil
 	}
-
-	var n uint64
-	var i int64
-	err := json.Unmarshal(b, &i)
-	switch err.(type) {
-	case nil:
-		n = uint64(i)
-	case *json.UnmarshalTypeError:
-		// The only valid reason for accepting a uint64 that does not fit into an int64
-		// is for erroneous -1 values being converted to uint64.
-		// Nevertheless, try unmarshaling it and error out if it's not a number at all.
-		if err := json.Unmarshal(b, &n); err != nil {
-			return err
-		}
-	default:
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
 		return err
 	}
-	if n >= 0 && n <= 100 {
-		m.V = &n
-	} else {
-		m.V = nil
+	v := uint64(swappinessDefault)
+	if s := n.String(); s != "-1" && s != "18446744073709551615" {
+		var err error
+		if v, err = strconv.ParseUint(s, 10, 64); err != nil || v > 100 {
+			return fmt.Errorf("invalid memory swappiness %s: expected -1 or a value between 0 and 100", b)
+		}
 	}
+	m.V = &v
 	return nil
 }
 
+// CgroupSwappiness is a MemorySwappiness in the runc state, where runc writes
+// null for the default of the kernel since Docker 17.06.1.
+type CgroupSwappiness MemorySwappiness
+
+func (c CgroupSwappiness) String() string {
+	return MemorySwappiness(c).String()
+}
+
+func (c CgroupSwappiness) MarshalJSON() ([]byte, error) {
+	if c.V != nil && *c.V == swappinessDefault {
+		return null, nil
+	}
+	return MemorySwappiness(c).MarshalJSON()
+}
+
+func (c *CgroupSwappiness) UnmarshalJSON(b []byte) error {
+	return (*MemorySwappiness)(c).UnmarshalJSON(b)
+}
+
 type linuxCapabilities struct {
 	V *specs.LinuxCapabilities
 }
@@ -91,29 +189,181 @@ func (l *linuxCapabilities) MarshalJSON() ([]byte, error) {
 	return json.Marshal(l.V)
 }
 
+// Decoded returns the type of the capability sets. The flat lists written
+// before Docker 17.06 have no fields.
+func (l *linuxCapabilities) Decoded() interface{} {
+	return &specs.LinuxCapabilities{}
+}
+
 func (l *linuxCapabilities) UnmarshalJSON(b []byte) error {
+	// the policy of the upgrade, if not the default, is applied by
+	// expandCapabilities.
+	sets, err := DecodeCapabilities(b, upgrade.DockerCapabilities, nil)
+	if err != nil || sets == nil {
+		return err
+	}
+	l.V = capabilitySets(*sets)
+	return nil
+}
+
+// DecodeCapabilities decodes the capabilities of a process, written as
+// capability sets since Docker 17.06, or as a flat list before, which is
+// expanded by policy for the user uid. It returns nil for null.
+func DecodeCapabilities(b []byte, policy upgrade.CapabilityPolicy, uid *uint32) (*upgrade.CapabilitySets, error) {
 	if bytes.Compare(b, null) == 0 {
-		return nil
+		return nil, nil
+	}
+	var s struct {
+		Bounding    []string `json:"bounding,omitempty"`
+		Effective   []string `json:"effective,omitempty"`
+		Inheritable []string `json:"inheritable,omitempty"`
+		Permitted   []string `json:"permitted,omitempty"`
+		Ambient     []string `json:"ambient,omitempty"`
 	}
-	var s specs.LinuxCapabilities
 	err := json.Unmarshal(b, &s)
 	switch err.(type) {
 	case nil:
-		l.V = &s
+		sets := upgrade.CapabilitySets(s)
+		return &sets, nil
 	case *json.UnmarshalTypeError:
 		var caps []string
-		err = json.Unmarshal(b, &caps)
-		if err != nil {
+		if err := json.Unmarshal(b, &caps); err != nil {
+			return nil, err
+		}
+		sets := policy(caps, uid)
+		return &sets, nil
+	}
+	return nil, err
+}
+
+// capabilitySets returns s as runtime-spec capabilities.
+func capabilitySets(s upgrade.CapabilitySets) *specs.LinuxCapabilities {
+	return &specs.LinuxCapabilities{
+		Bounding:    s.Bounding,
+		Effective:   s.Effective,
+		Inheritable: s.Inheritable,
+		Permitted:   s.Permitted,
+		Ambient:     s.Ambient,
+	}
+}
+
+// expand sets l to the capability sets given by policy if b, the encoding l
+// was decoded from, is a flat list of capabilities.
+func (l *linuxCapabilities) expand(b json.RawMessage, policy upgrade.CapabilityPolicy, uid *uint32) error {
+	if b = bytes.TrimSpace(b); len(b) == 0 || b[0] != '[' {
+		return nil
+	}
+	var caps []string
+	if err := json.Unmarshal(b, &caps); err != nil {
+		return err
+	}
+	l.V = capabilitySets(policy(caps, uid))
+	return nil
+}
+
+// expandCapabilities applies policy to the flat capability lists of x, which
+// was decoded from data. The runc state does not record the user of the
+// process, the containerd files do: upgrade.Upgrade gives policy the user of
+// the config when converting the state.
+func expandCapabilities(data []byte, x interface{}, policy upgrade.CapabilityPolicy) error {
+	switch x := x.(type) {
+	case *State:
+		var raw struct {
+			Config struct {
+				Capabilities json.RawMessage `json:"capabilities"`
+			} `json:"config"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		return x.Config.Capabilities.expand(raw.Config.Capabilities, policy, nil)
+	case *Spec:
+		var raw struct {
+			Process struct {
+				Capabilities json.RawMessage `json:"capabilities"`
+			} `json:"process"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
 			return err
 		}
-		// TODO: copy caps or not copy caps?
-		l.V = &specs.LinuxCapabilities{
-			Bounding:    caps,
-			Effective:   caps,
-			Inheritable: caps,
-			Permitted:   caps,
-			Ambient:     nil,
+		uid := x.Process.User.UID
+		return x.Process.Capabilities.expand(raw.Process.Capabilities, policy, &uid)
+	case *ProcessState:
+		var raw struct {
+			Capabilities json.RawMessage `json:"capabilities"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		uid := x.User.UID
+		return x.Capabilities.expand(raw.Capabilities, policy, &uid)
+	}
+	return nil
+}
+
+type linuxBlockIO struct {
+	specs.LinuxBlockIO
+}
+
+// LegacyBlockIO holds the "blkio" prefixed names of the block IO settings,
+// used before runtime-spec v1.0.0-rc5.
+type LegacyBlockIO struct {
+	BlkioWeight                  *uint16                     `json:"blkioWeight,omitempty"`
+	BlkioLeafWeight              *uint16                     `json:"blkioLeafWeight,omitempty"`
+	BlkioWeightDevice            []specs.LinuxWeightDevice   `json:"blkioWeightDevice,omitempty"`
+	BlkioThrottleReadBpsDevice   []specs.LinuxThrottleDevice `json:"blkioThrottleReadBpsDevice,omitempty"`
+	BlkioThrottleWriteBpsDevice  []specs.LinuxThrottleDevice `json:"blkioThrottleWriteBpsDevice,omitempty"`
+	BlkioThrottleReadIOPSDevice  []specs.LinuxThrottleDevice `json:"blkioThrottleReadIOPSDevice,omitempty"`
+	BlkioThrottleWriteIOPSDevice []specs.LinuxThrottleDevice `json:"blkioThrottleWriteIOPSDevice,omitempty"`
+}
+
+// linuxBlockIOJSON is the block IO of the cgroup with the names of
+// runtime-spec v1.0.0-rc5, or with the ones used before.
+type linuxBlockIOJSON struct {
+	specs.LinuxBlockIO
+	LegacyBlockIO
+}
+
+func (l *linuxBlockIO) Decoded() interface{} {
+	return &linuxBlockIOJSON{}
+}
+
+// UnmarshalJSON accepts the "blkio" prefixed names used before
+// runtime-spec v1.0.0-rc5.
+func (l *linuxBlockIO) UnmarshalJSON(b []byte) error {
+	b, err := UpgradeBlockIO(b)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &l.LinuxBlockIO)
+}
+
+// UpgradeBlockIO renames the settings of the block IO b, a JSON object, that
+// have the names of LegacyBlockIO to the names of runtime-spec v1.0.0-rc5.
+func UpgradeBlockIO(b []byte) ([]byte, error) {
+	var v map[string]json.RawMessage
+	if err := json.Unmarshal(b, &v); err != nil || v == nil {
+		return b, err
+	}
+	names := make([]string, 0, len(blockIONames))
+	for name := range blockIONames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		old := blockIONames[name]
+		value, ok := v[old]
+		if !ok {
+			continue
+		}
+		delete(v, old)
+		if bytes.Compare(value, null) == 0 {
+			continue
+		}
+		if current, ok := v[name]; ok && bytes.Compare(current, null) != 0 {
+			return nil, fmt.Errorf("found incompatible '%s' and '%s' fields", old, name)
 		}
+		v[name] = value
 	}
-	return err
+	return json.Marshal(v)
 }
@@ -0,0 +1,295 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+// TestStrictFixtures checks that the files written by the versions upgraded
+// by this package decode without unexpected fields.
+func TestStrictFixtures(t *testing.T) {
+	for _, v := range []string{"17.03", "17.05", "17.06.0"} {
+		for _, d := range [...]struct {
+			name string
+			v    interface{}
+		}{
+			{"state.json", new(State)},
+			{"config.json", new(Spec)},
+			{"process.json", new(ProcessState)},
+		} {
+			name := filepath.Join("..", "testfiles", d.name+"-"+v)
+			b, err := ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := upgrade.DecodeStrict(b, d.v); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+	}
+}
+
+func TestBlockIO(t *testing.T) {
+	var s Spec
+	if err := upgrade.DecodeStrict([]byte(`{"linux":{"resources":{"blockIO":{"blkioWeight":10,"blkioThrottleReadBpsDevice":[{"major":8,"minor":0,"rate":100}]}}}}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	b := s.Linux.Resources.BlockIO
+	if b.Weight == nil || *b.Weight != 10 {
+		t.Fatalf("weight: %v", b.Weight)
+	}
+	if len(b.ThrottleReadBpsDevice) != 1 || b.ThrottleReadBpsDevice[0].Rate != 100 {
+		t.Fatalf("throttleReadBpsDevice: %v", b.ThrottleReadBpsDevice)
+	}
+	if err := s.Linux.Resources.BlockIO.UnmarshalJSON([]byte(`{"blkioWeight":10,"weight":10}`)); err == nil {
+		t.Fatal("expected an error for both blkioWeight and weight")
+	}
+}
+
+func TestInitProcessStartTime(t *testing.T) {
+	for _, d := range [...]struct {
+		version              string
+		initProcessStartTime InitProcessStartTime
+	}{
+		{"17.03", 8468990},
+		{"17.05", 8504596},
+		{"17.06.0", 8497004},
+		{"17.06.1", 8497004},
+	} {
+		name := filepath.Join("..", "testfiles", "state.json-"+d.version)
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s State
+		if err := json.Unmarshal(b, &s); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if s.InitProcessStartTime != d.initProcessStartTime {
+			t.Fatalf("%s: %d | %d", name, d.initProcessStartTime, s.InitProcessStartTime)
+		}
+		out, err := json.Marshal(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf(`"init_process_start":%d,`, d.initProcessStartTime); !strings.Contains(string(out), expected) {
+			t.Fatalf("%s: %s not found in the upgraded state", name, expected)
+		}
+	}
+
+	for _, b := range []string{`""`, `"abc"`, `-1`, `1.5`, `"12 "`, `true`} {
+		var v InitProcessStartTime
+		if err := json.Unmarshal([]byte(b), &v); err == nil {
+			t.Fatalf("%s: expected an error, got %d", b, v)
+		}
+	}
+}
+
+func TestMemorySwappiness(t *testing.T) {
+	for _, d := range [...]struct {
+		name, value string
+		// expected is the setting decoded, compared by effect.
+		expected MemorySwappiness
+		// config and state are the swappiness written back in
+		// config.json and in state.json.
+		config, state string
+	}{
+		{"unset", `null`, MemorySwappiness{nil}, `{}`, `null`},
+		{"default", `-1`, MemorySwappiness{nil}, `{"swappiness":18446744073709551615}`, `null`},
+		{"default as unsigned", `18446744073709551615`, MemorySwappiness{nil}, `{"swappiness":18446744073709551615}`, `null`},
+		{"zero", `0`, swappiness(0), `{"swappiness":0}`, `0`},
+		{"value", `60`, swappiness(60), `{"swappiness":60}`, `60`},
+		{"maximum", `100`, swappiness(100), `{"swappiness":100}`, `100`},
+	} {
+		var spec Spec
+		if err := json.Unmarshal([]byte(`{"linux":{"resources":{"memory":{"swappiness":`+d.value+`}}}}`), &spec); err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		memory := spec.Linux.Resources.Memory
+		actual := MemorySwappiness{nil}
+		if memory.Swappiness != nil {
+			actual = *memory.Swappiness
+		}
+		if d.expected.Compare(actual) != 0 {
+			t.Fatalf("%s: config: expected %s, got %s", d.name, d.expected, actual)
+		}
+		if b, err := json.Marshal(memory); err != nil || string(b) != d.config {
+			t.Fatalf("%s: config: expected %s, got %s (%v)", d.name, d.config, b, err)
+		}
+
+		var s State
+		if err := json.Unmarshal([]byte(`{"config":{"cgroups":{"memory_swappiness":`+d.value+`}}}`), &s); err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		if d.expected.Compare(MemorySwappiness(s.Config.Cgroups.MemorySwappiness)) != 0 {
+			t.Fatalf("%s: state: expected %s, got %s", d.name, d.expected, s.Config.Cgroups.MemorySwappiness)
+		}
+		if b, err := json.Marshal(s.Config.Cgroups.MemorySwappiness); err != nil || string(b) != d.state {
+			t.Fatalf("%s: state: expected %s, got %s (%v)", d.name, d.state, b, err)
+		}
+	}
+
+	// the explicit default is told apart from an unset swappiness.
+	var m MemorySwappiness
+	if err := json.Unmarshal([]byte(`-1`), &m); err != nil || m.V == nil || *m.V != swappinessDefault {
+		t.Fatalf("-1: %s, %v", m, err)
+	}
+
+	for _, b := range []string{`101`, `-2`, `1.5`, `"abc"`, `true`} {
+		var m MemorySwappiness
+		if err := json.Unmarshal([]byte(b), &m); err == nil {
+			t.Fatalf("%s: expected an error, got %s", b, m)
+		}
+	}
+}
+
+func swappiness(v uint64) MemorySwappiness {
+	return MemorySwappiness{&v}
+}
+
+// TestUpgradeSwappiness checks the swappiness written by the upgrade of the
+// fixtures, and of the fixtures with every other value.
+func TestUpgradeSwappiness(t *testing.T) {
+	for _, d := range [...]struct {
+		version string
+		// value replaces the swappiness of the fixtures if not empty.
+		value         string
+		config, state string
+	}{
+		{"17.03", "", `"swappiness":18446744073709551615`, `"memory_swappiness":null`},
+		{"17.05", "", `"swappiness":18446744073709551615`, `"memory_swappiness":null`},
+		{"17.06.0", "", `"swappiness":18446744073709551615`, `"memory_swappiness":null`},
+		{"17.03", "60", `"swappiness":60`, `"memory_swappiness":60`},
+		{"17.03", "0", `"swappiness":0`, `"memory_swappiness":0`},
+		{"17.03", "null", `"memory":{}`, `"memory_swappiness":null`},
+	} {
+		c, fs := fixtureContainer(t, d.version, func(kind upgrade.Kind, b []byte) []byte {
+			if d.value == "" {
+				return b
+			}
+			for _, old := range []string{`"swappiness":18446744073709551615`, `"memory_swappiness":-1`} {
+				b = bytes.Replace(b, []byte(old), []byte(old[:strings.Index(old, ":")+1]+d.value), 1)
+			}
+			return b
+		})
+		if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true}); err != nil {
+			t.Fatalf("%s %s: %v", d.version, d.value, err)
+		}
+		for _, f := range []struct{ path, expected string }{{c.Config, d.config}, {c.State, d.state}} {
+			b, err := readFile(fs, f.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(b, []byte(f.expected)) {
+				t.Fatalf("%s %s: %s not found in %s", d.version, d.value, f.expected, f.path)
+			}
+		}
+	}
+}
+
+// TestUnknownFieldsShims checks that the unknown fields of the values with a
+// custom decoder are reported, and carried through the upgrade on demand.
+func TestUnknownFieldsShims(t *testing.T) {
+	for _, d := range [...]struct {
+		name     string
+		v        interface{}
+		doc      string
+		expected string
+	}{
+		{"syscall", new(Spec), `{"linux":{"seccomp":{"syscalls":[{"name":"accept","action":"SCMP_ACT_ALLOW","fork":1}]}}}`, ".linux.seccomp.syscalls[0].fork"},
+		{"spec capabilities", new(Spec), `{"process":{"capabilities":{"bounding":["CAP_KILL"],"fork":1}}}`, ".process.capabilities.fork"},
+		{"process capabilities", new(ProcessState), `{"capabilities":{"bounding":["CAP_KILL"],"fork":1}}`, ".capabilities.fork"},
+		{"state capabilities", new(State), `{"config":{"capabilities":{"bounding":["CAP_KILL"],"fork":1}}}`, ".config.capabilities.fork"},
+		{"block IO", new(Spec), `{"linux":{"resources":{"blockIO":{"blkioWeight":10,"fork":1}}}}`, ".linux.resources.blockIO.fork"},
+	} {
+		fields, err := upgrade.UnknownFields([]byte(d.doc), d.v)
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		if len(fields) != 1 || fields[0].String() != d.expected {
+			t.Fatalf("%s: expected %s, got %v", d.name, d.expected, fields)
+		}
+	}
+
+	c, fs := fixtureContainer(t, "17.06.0", func(kind upgrade.Kind, b []byte) []byte {
+		b = bytes.Replace(b, []byte(`{"names":["accept"],`), []byte(`{"fork":1,"names":["accept"],`), 1)
+		return bytes.Replace(b, []byte(`"capabilities":{`), []byte(`"capabilities":{"fork":2,`), 1)
+	})
+	report, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, PreserveUnknown: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range report.Files {
+		if f.Path == c.Config && len(f.Unknown) != 2 {
+			t.Fatalf("%s: unknown fields: %v", f.Path, f.Unknown)
+		}
+	}
+	for path, expected := range map[string][]string{
+		c.Config:  {`"fork":1`, `"fork":2`},
+		c.Process: {`"fork":2`},
+	} {
+		b, err := readFile(fs, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range expected {
+			if !bytes.Contains(b, []byte(e)) {
+				t.Fatalf("%s: %s not preserved", path, e)
+			}
+		}
+	}
+}
+
+// TestStrictShims checks that strict decoding accepts the formats of the
+// values with a custom decoder, and rejects their unexpected fields.
+func TestStrictShims(t *testing.T) {
+	for _, d := range [...]struct {
+		name string
+		v    interface{}
+		doc  string
+		// expected is the unexpected field, if any.
+		expected string
+	}{
+		{"syscall name", new(Spec), `{"linux":{"seccomp":{"syscalls":[{"name":"accept","action":"SCMP_ACT_ALLOW"}]}}}`, ""},
+		{"syscall names", new(Spec), `{"linux":{"seccomp":{"syscalls":[{"names":["accept"],"action":"SCMP_ACT_ALLOW","args":null,"comment":""}]}}}`, ""},
+		{"syscall", new(Spec), `{"linux":{"seccomp":{"syscalls":[{"names":["accept"],"action":"SCMP_ACT_ALLOW","fork":1}]}}}`, ".linux.seccomp.syscalls[0].fork"},
+		{"capability list", new(ProcessState), `{"capabilities":["CAP_KILL"]}`, ""},
+		{"capability sets", new(ProcessState), `{"capabilities":{"bounding":["CAP_KILL"],"ambient":[]}}`, ""},
+		{"spec capabilities", new(Spec), `{"process":{"capabilities":{"bounding":["CAP_KILL"],"fork":1}}}`, ".process.capabilities.fork"},
+		{"process capabilities", new(ProcessState), `{"capabilities":{"bounding":["CAP_KILL"],"fork":1}}`, ".capabilities.fork"},
+		{"state capabilities", new(State), `{"config":{"capabilities":{"bounding":["CAP_KILL"],"fork":1}}}`, ".config.capabilities.fork"},
+		{"block IO blkio", new(Spec), `{"linux":{"resources":{"blockIO":{"blkioWeight":10,"blkioLeafWeight":10}}}}`, ""},
+		{"block IO", new(Spec), `{"linux":{"resources":{"blockIO":{"weight":10,"leafWeight":10}}}}`, ""},
+		{"block IO fork", new(Spec), `{"linux":{"resources":{"blockIO":{"weight":10,"fork":1}}}}`, ".linux.resources.blockIO.fork"},
+	} {
+		err := upgrade.DecodeStrict([]byte(d.doc), d.v)
+		if d.expected == "" {
+			if err != nil {
+				t.Fatalf("%s: %v", d.name, err)
+			}
+			continue
+		}
+		e, ok := err.(*upgrade.UnexpectedFieldsError)
+		if !ok {
+			t.Fatalf("%s: expected an *UnexpectedFieldsError, got %v", d.name, err)
+		}
+		if len(e.Fields) != 1 || e.Fields[0].String() != d.expected {
+			t.Fatalf("%s: expected %s, got %v", d.name, d.expected, e.Fields)
+		}
+	}
+
+	c, _ := fixtureContainer(t, "17.03", func(kind upgrade.Kind, b []byte) []byte {
+		return bytes.Replace(b, []byte(`"blockIO":{`), []byte(`"blockIO":{"fork":1,`), 1)
+	})
+	_, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, Strict: true})
+	if err == nil || !strings.Contains(err.Error(), ".linux.resources.blockIO.fork") {
+		t.Fatalf("expected an unexpected field error, got %v", err)
+	}
+}
@@ -4,60 +4,108 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"os"
-	"strings"
+	"path/filepath"
 
-	"github.com/docker/docker/pkg/ioutils"
+	"github.com/crosbymichael/upgrade"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
-type file struct {
-	name string
-	x    interface{}
-	buf  bytes.Buffer
-	w    io.WriteCloser
+// Version is the version of the state files produced by this package.
+const Version = upgrade.V17_06_1
+
+func init() {
+	// the decoders accept the formats of all the earlier releases.
+	for _, v := range []upgrade.Version{upgrade.V17_03, upgrade.V17_05, upgrade.V17_06_0} {
+		upgrade.Register(upgrader{from: v})
+	}
 }
 
-func Upgrade(runcState, containerdConfig, containerdProcess string) error {
-	files := []*file{
-		&file{name: runcState, x: new(State)},
-		&file{name: containerdConfig, x: new(Spec)},
-		&file{name: containerdProcess, x: new(ProcessState)},
-	}
-	for _, f := range files {
-		fd, err := os.Open(f.name)
-		if err != nil {
-			return err
-		}
-		defer fd.Close()
-		// error out if any of the files have issues being decoded
-		// before overwriting them, to prevent being in a mixed state.
-		if err := json.NewDecoder(fd).Decode(f.x); err != nil {
-			return err
-		}
-		// error out if any of the files have issues being encoded
-		// before overwriting them, to prevent being in a mixed state.
-		if err := json.NewEncoder(&f.buf).Encode(f.x); err != nil {
-			return err
-		}
-		fi, err := fd.Stat()
-		if err != nil {
-			return err
-		}
-		f.w, err = ioutils.NewAtomicFileWriter(f.name, fi.Mode())
-		if err != nil {
-			return err
-		}
-		defer f.w.Close()
+type upgrader struct {
+	from upgrade.Version
+}
+
+func (u upgrader) From() upgrade.Version { return u.from }
+
+func (u upgrader) To() upgrade.Version { return Version }
+
+func (u upgrader) Upgrade(doc *upgrade.Document) error {
+	return u.UpgradeOptions(doc, upgrade.Options{})
+}
+
+// UpgradeOptions expands the flat capability lists of doc with
+// opts.Capabilities.
+func (u upgrader) UpgradeOptions(doc *upgrade.Document, opts upgrade.Options) error {
+	var x interface{}
+	switch doc.Kind {
+	case upgrade.KindState:
+		x = new(State)
+	case upgrade.KindConfig:
+		x = new(Spec)
+	case upgrade.KindProcess:
+		x = new(ProcessState)
+	default:
+		return fmt.Errorf("unsupported document kind: %v", doc.Kind)
 	}
-	var errs []string
-	for _, f := range files {
-		if _, err := f.w.Write(f.buf.Bytes()); err != nil {
-			errs = append(errs, fmt.Sprintf("error writing to %s: %v", f.name, err))
+	if err := json.Unmarshal(doc.Data, x); err != nil {
+		return &upgrade.DecodeError{Err: err}
+	}
+	if opts.Capabilities != nil {
+		if err := expandCapabilities(doc.Data, x, opts.Capabilities); err != nil {
+			return &upgrade.DecodeError{Err: err}
 		}
 	}
-	if errs != nil {
-		return fmt.Errorf(strings.Join(errs, ", "))
+	dropConsoleSize(x)
+	unknown, err := upgrade.UnknownFields(doc.Data, x)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(x); err != nil {
+		return err
 	}
+	doc.Data = buf.Bytes()
+	doc.Unknown = unknown
 	return nil
 }
+
+// dropConsoleSize removes the empty console size that the earlier versions
+// always wrote in processes, and that 17.06.1 omits.
+func dropConsoleSize(x interface{}) {
+	var size **specs.Box
+	switch x := x.(type) {
+	case *Spec:
+		size = &x.Process.ConsoleSize
+	case *ProcessState:
+		size = &x.ConsoleSize
+	default:
+		return
+	}
+	if *size != nil && **size == (specs.Box{}) {
+		*size = nil
+	}
+}
+
+// container returns the container of the given files. Its ID is the name of
+// the directory of the runc state, as laid out by Docker.
+func container(runcState, containerdConfig, containerdProcess string) upgrade.Container {
+	return upgrade.Container{
+		ID:      filepath.Base(filepath.Dir(runcState)),
+		State:   runcState,
+		Config:  containerdConfig,
+		Process: containerdProcess,
+	}
+}
+
+// NeedsUpgrade reports whether any of the given files was written by an
+// earlier version.
+func NeedsUpgrade(runcState, containerdConfig, containerdProcess string) (bool, error) {
+	return upgrade.NeedsUpgrade(container(runcState, containerdConfig, containerdProcess), Version)
+}
+
+// Upgrade converts the given files to the format of Docker 17.06.1. It does
+// not check whether the init process recorded in runcState is still running;
+// see upgrade.Upgrade for that.
+func Upgrade(runcState, containerdConfig, containerdProcess string) error {
+	_, err := upgrade.Upgrade(container(runcState, containerdConfig, containerdProcess), upgrade.Options{Target: Version, IgnoreLiveness: true})
+	return err
+}
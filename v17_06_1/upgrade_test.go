@@ -0,0 +1,47 @@
+package v17_06_1
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+// TestLegacyUpgrade checks that the path based Upgrade converts the files of
+// a container whatever the state of its init process, as it always has.
+func TestLegacyUpgrade(t *testing.T) {
+	root, err := ioutil.TempDir("", "upgrade-legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	c := upgrade.NewContainer(filepath.Join(root, "runc"), filepath.Join(root, "containerd"), fixtureID)
+	for _, f := range []struct{ path, fixture string }{
+		{c.State, "state.json"},
+		{c.Config, "config.json"},
+		{c.Process, "process.json"},
+	} {
+		b, err := ioutil.ReadFile(filepath.Join("..", "testfiles", f.fixture+"-17.03"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(f.path, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if id := container(c.State, c.Config, c.Process).ID; id != fixtureID {
+		t.Fatalf("container ID: %q", id)
+	}
+	if err := Upgrade(c.State, c.Config, c.Process); err != nil {
+		t.Fatal(err)
+	}
+	if needed, err := NeedsUpgrade(c.State, c.Config, c.Process); err != nil || needed {
+		t.Fatalf("needs upgrade: %v, %v", needed, err)
+	}
+}
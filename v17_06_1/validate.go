@@ -0,0 +1,245 @@
+package v17_06_1
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/crosbymichael/upgrade"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func init() {
+	// the decoders accept the formats of all the earlier releases, and so
+	// does the validator.
+	for _, v := range []upgrade.Version{upgrade.V17_03, upgrade.V17_05, upgrade.V17_06_0, Version} {
+		upgrade.RegisterValidator(validator{version: v})
+	}
+}
+
+type validator struct {
+	version upgrade.Version
+}
+
+// NewValidator returns the validator of this package for the documents of the
+// given version, which the decoders of this package must accept. It lets the
+// packages of later versions that write the same formats reuse the checks.
+func NewValidator(version upgrade.Version) upgrade.DocumentValidator {
+	return validator{version: version}
+}
+
+func (v validator) Version() upgrade.Version { return v.version }
+
+// Validate cross-checks the runc state with the bundle config, and the bundle
+// config with the state of the init process, the way runc derived the former
+// from the latter. The checks involving a missing document are skipped.
+func (v validator) Validate(c upgrade.Container, docs []*upgrade.Document) ([]upgrade.Mismatch, error) {
+	var (
+		s    State
+		spec Spec
+		p    ProcessState
+		have = make(map[upgrade.Kind]bool)
+	)
+	for _, doc := range docs {
+		if doc.Kind == upgrade.KindProcess && doc.Path != c.Process {
+			// exec processes are checked by runc when they start.
+			continue
+		}
+		var x interface{}
+		switch doc.Kind {
+		case upgrade.KindState:
+			x = &s
+		case upgrade.KindConfig:
+			x = &spec
+		case upgrade.KindProcess:
+			x = &p
+		default:
+			return nil, fmt.Errorf("unsupported document kind: %v", doc.Kind)
+		}
+		if err := json.Unmarshal(doc.Data, x); err != nil {
+			return nil, &upgrade.DecodeError{File: doc.Path, Kind: doc.Kind, Err: err}
+		}
+		have[doc.Kind] = true
+	}
+
+	var mismatches []upgrade.Mismatch
+	add := func(reason string, kinds ...upgrade.Kind) {
+		mismatches = append(mismatches, upgrade.Mismatch{Kinds: kinds, Reason: reason})
+	}
+	if have[upgrade.KindState] {
+		if dir := filepath.Base(filepath.Dir(c.Config)); c.Config != "" && s.ID != dir {
+			add(fmt.Sprintf("the runc state is of container %q, found in the directory of %q", s.ID, dir), upgrade.KindState)
+		}
+	}
+	if have[upgrade.KindState] && have[upgrade.KindConfig] {
+		mismatches = append(mismatches, validateStateSpec(c, &s, &spec)...)
+	}
+	if have[upgrade.KindConfig] && have[upgrade.KindProcess] {
+		for _, d := range [...]struct {
+			name            string
+			config, process interface{}
+		}{
+			{"args", emptyIfNil(spec.Process.Args), emptyIfNil(p.Args)},
+			{"env", emptyIfNil(spec.Process.Env), emptyIfNil(p.Env)},
+			{"cwd", spec.Process.Cwd, p.Cwd},
+			{"user", normalizeUser(spec.Process.User), normalizeUser(p.User)},
+		} {
+			if !reflect.DeepEqual(d.config, d.process) {
+				add(fmt.Sprintf("the %s of the init process do not match: %v in the config, %v in the process state", d.name, d.config, d.process), upgrade.KindConfig, upgrade.KindProcess)
+			}
+		}
+		if set := differentCapabilities(spec.Process.Capabilities.V, p.Capabilities.V); set != "" {
+			add(fmt.Sprintf("the %s capabilities of the init process differ between the config and the process state", set), upgrade.KindConfig, upgrade.KindProcess)
+		}
+	}
+	return mismatches, nil
+}
+
+// validateStateSpec checks the runc configuration recorded in s against the
+// bundle config it was created from.
+func validateStateSpec(c upgrade.Container, s *State, spec *Spec) []upgrade.Mismatch {
+	var mismatches []upgrade.Mismatch
+	add := func(format string, args ...interface{}) {
+		mismatches = append(mismatches, upgrade.Mismatch{
+			Kinds:  []upgrade.Kind{upgrade.KindState, upgrade.KindConfig},
+			Reason: fmt.Sprintf(format, args...),
+		})
+	}
+
+	// runc resolves a relative root against the bundle.
+	root := spec.Root.Path
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(filepath.Dir(c.Config), root)
+	}
+	if filepath.Clean(s.Config.Rootfs) != filepath.Clean(root) {
+		add("the rootfs is %s in the runc state, %s in the config", s.Config.Rootfs, spec.Root.Path)
+	}
+
+	// the runc state only records the capabilities since 17.06.
+	if s.Config.Capabilities.V != nil {
+		if set := differentCapabilities(s.Config.Capabilities.V, spec.Process.Capabilities.V); set != "" {
+			add("the %s capabilities differ between the runc state and the config", set)
+		}
+	}
+
+	var stateMounts, specMounts []string
+	for _, m := range s.Config.Mounts {
+		stateMounts = append(stateMounts, filepath.Clean(m.Destination))
+	}
+	for _, m := range spec.Mounts {
+		specMounts = append(specMounts, filepath.Clean(m.Destination))
+	}
+	if missing, extra := difference(specMounts, stateMounts); missing != nil || extra != nil {
+		add("the mounts differ: %v missing from the runc state, %v not in the config", missing, extra)
+	}
+
+	var stateNamespaces, specNamespaces []string
+	for _, ns := range s.Config.Namespaces {
+		stateNamespaces = append(stateNamespaces, fmt.Sprintf("%s %s", ns.Type, ns.Path))
+	}
+	var cgroupsPath string
+	if spec.Linux != nil {
+		for _, ns := range spec.Linux.Namespaces {
+			// runc only knows these namespaces.
+			if t, ok := namespaceTypes[ns.Type]; ok {
+				specNamespaces = append(specNamespaces, fmt.Sprintf("%s %s", t, ns.Path))
+			}
+		}
+		cgroupsPath = spec.Linux.CgroupsPath
+	}
+	if missing, extra := difference(specNamespaces, stateNamespaces); missing != nil || extra != nil {
+		add("the namespaces differ: %v missing from the runc state, %v not in the config", missing, extra)
+	}
+
+	if s.Config.Cgroups != nil && cgroupsPath != "" {
+		path := s.Config.Cgroups.Path
+		if s.Config.Cgroups.Name != "" {
+			// the systemd driver splits "slice:prefix:name".
+			path = s.Config.Cgroups.Parent + ":" + s.Config.Cgroups.ScopePrefix + ":" + s.Config.Cgroups.Name
+		}
+		if path != cgroupsPath {
+			add("the cgroups path is %s in the runc state, %s in the config", path, cgroupsPath)
+		}
+	}
+	return mismatches
+}
+
+// namespaceTypes maps the namespace types of the runtime spec to the ones of
+// the runc state.
+var namespaceTypes = map[specs.LinuxNamespaceType]configs.NamespaceType{
+	specs.PIDNamespace:     configs.NEWPID,
+	specs.NetworkNamespace: configs.NEWNET,
+	specs.MountNamespace:   configs.NEWNS,
+	specs.IPCNamespace:     configs.NEWIPC,
+	specs.UTSNamespace:     configs.NEWUTS,
+	specs.UserNamespace:    configs.NEWUSER,
+}
+
+// differentCapabilities returns the name of the first capability set that
+// differs between a and b, ignoring the order, or "" if they are the same.
+func differentCapabilities(a, b *specs.LinuxCapabilities) string {
+	if a == nil {
+		a = &specs.LinuxCapabilities{}
+	}
+	if b == nil {
+		b = &specs.LinuxCapabilities{}
+	}
+	for _, d := range [...]struct {
+		name string
+		a, b []string
+	}{
+		{"bounding", a.Bounding, b.Bounding},
+		{"effective", a.Effective, b.Effective},
+		{"inheritable", a.Inheritable, b.Inheritable},
+		{"permitted", a.Permitted, b.Permitted},
+		{"ambient", a.Ambient, b.Ambient},
+	} {
+		if missing, extra := difference(d.a, d.b); missing != nil || extra != nil {
+			return d.name
+		}
+	}
+	return ""
+}
+
+// difference returns the sorted elements of a that are not in b, and of b
+// that are not in a.
+func difference(a, b []string) (missing, extra []string) {
+	inA := make(map[string]bool)
+	inB := make(map[string]bool)
+	for _, s := range a {
+		inA[s] = true
+	}
+	for _, s := range b {
+		inB[s] = true
+	}
+	for s := range inA {
+		if !inB[s] {
+			missing = append(missing, s)
+		}
+	}
+	for s := range inB {
+		if !inA[s] {
+			extra = append(extra, s)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
+
+func emptyIfNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func normalizeUser(u specs.User) specs.User {
+	if len(u.AdditionalGids) == 0 {
+		u.AdditionalGids = nil
+	}
+	return u
+}
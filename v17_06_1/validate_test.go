@@ -0,0 +1,78 @@
+package v17_06_1
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+)
+
+func TestValidate(t *testing.T) {
+	for _, version := range []string{"17.03", "17.05", "17.06.0", "17.06.1"} {
+		c, _ := fixtureContainer(t, version, nil)
+		docs, err := upgrade.Load(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := upgrade.Validate(c, docs, Version); err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+	}
+
+	for _, d := range [...]struct {
+		name     string
+		kind     upgrade.Kind
+		old, new string
+		// reason is part of the reason of the only mismatch.
+		reason string
+		files  []upgrade.Kind
+	}{
+		{"id", upgrade.KindState, `"id":"50f0`, `"id":"60f0`, "the runc state is of container", []upgrade.Kind{upgrade.KindState}},
+		{"rootfs", upgrade.KindState, `"rootfs":"/var/lib/docker/aufs`, `"rootfs":"/var/lib/docker/overlay2`, "the rootfs", []upgrade.Kind{upgrade.KindState, upgrade.KindConfig}},
+		{"mounts", upgrade.KindState, `"/etc/hostname"`, `"/etc/hostnames"`, "[/etc/hostname] missing from the runc state, [/etc/hostnames] not in the config", []upgrade.Kind{upgrade.KindState, upgrade.KindConfig}},
+		{"namespaces", upgrade.KindState, `{"type":"NEWIPC","path":""}`, `{"type":"NEWIPC","path":"/proc/1/ns/ipc"}`, "the namespaces differ", []upgrade.Kind{upgrade.KindState, upgrade.KindConfig}},
+		{"cgroups path", upgrade.KindState, `"path":"/docker/`, `"path":"/system.slice/`, "the cgroups path", []upgrade.Kind{upgrade.KindState, upgrade.KindConfig}},
+		{"args", upgrade.KindProcess, `"/sleeping-beauty"`, `"/bin/sh"`, "the args of the init process", []upgrade.Kind{upgrade.KindConfig, upgrade.KindProcess}},
+		{"cwd", upgrade.KindProcess, `"cwd":"/"`, `"cwd":"/tmp"`, "the cwd of the init process", []upgrade.Kind{upgrade.KindConfig, upgrade.KindProcess}},
+		{"user", upgrade.KindProcess, `"uid":0`, `"uid":1000`, "the user of the init process", []upgrade.Kind{upgrade.KindConfig, upgrade.KindProcess}},
+		{"capabilities", upgrade.KindProcess, `"CAP_KILL",`, ``, "the bounding capabilities of the init process", []upgrade.Kind{upgrade.KindConfig, upgrade.KindProcess}},
+	} {
+		c, _ := fixtureContainer(t, "17.03", func(kind upgrade.Kind, b []byte) []byte {
+			if kind != d.kind {
+				return b
+			}
+			return bytes.Replace(b, []byte(d.old), []byte(d.new), 1)
+		})
+		docs, err := upgrade.Load(c)
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		err = upgrade.Validate(c, docs, Version)
+		var errs upgrade.Errors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Fatalf("%s: expected a single mismatch, got %v", d.name, err)
+		}
+		var e *upgrade.ConsistencyError
+		if !errors.As(errs[0], &e) || !strings.Contains(e.Reason, d.reason) || e.Container != fixtureID || len(e.Files) != len(d.files) {
+			t.Fatalf("%s: unexpected error: %v", d.name, errs[0])
+		}
+		for i, kind := range d.files {
+			if e.Files[i] != docs[kind].Path {
+				t.Fatalf("%s: unexpected files: %v", d.name, e.Files)
+			}
+		}
+
+		// nothing is written.
+		if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true}); !errors.As(err, &e) {
+			t.Fatalf("%s: unexpected error: %v", d.name, err)
+		}
+		if backups, err := upgrade.ListBackups(c); err != nil || len(backups) != 0 {
+			t.Fatalf("%s: backed up: %v, %v", d.name, backups, err)
+		}
+		if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true, SkipValidation: true}); err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+	}
+}
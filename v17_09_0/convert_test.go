@@ -0,0 +1,246 @@
+package v17_09_0
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+	"github.com/crosbymichael/upgrade/v17_06_1"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// fixtureContainer returns a container on an in-memory file system with the
+// fixtures of the given version as state files.
+func fixtureContainer(t *testing.T, version string) (upgrade.Container, *upgrade.MemFS) {
+	fs := upgrade.NewMemFS()
+	c := upgrade.NewContainer("/run/docker/runtime-runc/moby", "/var/run/docker/libcontainerd", "50f0834dd21e3e7a03f7373d2146d9026f7ebac96c6786015d78739cb299387f")
+	c.FS = fs
+	for _, f := range []struct{ path, fixture string }{
+		{c.State, "state.json"},
+		{c.Config, "config.json"},
+		{c.Process, "process.json"},
+	} {
+		b, err := ioutil.ReadFile(filepath.Join("..", "testfiles", f.fixture+"-"+version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.WriteFile(f.path, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c, fs
+}
+
+func readFile(fs upgrade.FS, path string) ([]byte, error) {
+	r, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// decodeFile decodes the file at path on fs to x, and returns its content.
+func decodeFile(t *testing.T, fs upgrade.FS, path string, x interface{}) []byte {
+	b, err := readFile(fs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, x); err != nil {
+		t.Fatalf("decode %s to %T: %v", path, x, err)
+	}
+	return b
+}
+
+func TestUpgrade(t *testing.T) {
+	for _, d := range [...]struct {
+		version              string
+		initProcessStartTime initProcessStartTimeType
+		// stateCaps are the capabilities recorded by runc, only since
+		// 17.06.
+		stateCaps *configs.Capabilities
+	}{
+		{"17.03", 8468990, nil},
+		{"17.05", 8504596, nil},
+		{"17.06.0", 8497004, &configs.Capabilities{Bounding: defaultCapsList, Effective: defaultCapsList, Inheritable: defaultCapsList, Permitted: defaultCapsList}},
+		{"17.06.1", 8497004, &configs.Capabilities{Bounding: defaultCapsList, Effective: defaultCapsList, Inheritable: defaultCapsList, Permitted: defaultCapsList}},
+	} {
+		c, fs := fixtureContainer(t, d.version)
+		if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true}); err != nil {
+			t.Fatalf("%s: %v", d.version, err)
+		}
+
+		var s State
+		b := decodeFile(t, fs, c.State, &s)
+		if s.InitProcessStartTime != d.initProcessStartTime {
+			t.Fatalf("%s (initProcessStartTime): %d | %d", d.version, d.initProcessStartTime, s.InitProcessStartTime)
+		}
+		if m := s.Config.Cgroups.Resources.MemorySwappiness; m.V != nil {
+			t.Fatalf("%s (memorySwappiness): %s", d.version, m)
+		}
+		if !reflect.DeepEqual(s.Config.Capabilities, d.stateCaps) {
+			t.Fatalf("%s (state capabilities): %#v | %#v", d.version, d.stateCaps, s.Config.Capabilities)
+		}
+		if s.Config.Version != specVersion {
+			t.Fatalf("%s (state version): %s", d.version, s.Config.Version)
+		}
+		// the resources are still written inline.
+		var raw struct {
+			Config struct {
+				Cgroups map[string]json.RawMessage `json:"cgroups"`
+			} `json:"config"`
+		}
+		if err := json.Unmarshal(b, &raw); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := raw.Config.Cgroups["memory_swappiness"]; !ok {
+			t.Fatalf("%s: cgroups %v", d.version, raw.Config.Cgroups)
+		}
+
+		var spec Spec
+		b = decodeFile(t, fs, c.Config, &spec)
+		// the platform and the OOM settings of the cgroup resources
+		// are gone.
+		if err := upgrade.DecodeStrict(b, new(Spec)); err != nil {
+			t.Fatalf("%s (config): %v", d.version, err)
+		}
+		if spec.Process.OOMScoreAdj == nil || spec.Linux.Resources.Memory.DisableOOMKiller == nil {
+			t.Fatalf("%s (OOM settings): %v, %v", d.version, spec.Process.OOMScoreAdj, spec.Linux.Resources.Memory.DisableOOMKiller)
+		}
+		if !reflect.DeepEqual(spec.Process.Capabilities.V, defaultCaps.V) {
+			t.Fatalf("%s (config capabilities): %#v", d.version, spec.Process.Capabilities.V)
+		}
+		if spec.Version != specVersion {
+			t.Fatalf("%s (ociVersion): %s", d.version, spec.Version)
+		}
+		var p ProcessState
+		decodeFile(t, fs, c.Process, &p)
+		if !reflect.DeepEqual(p.Capabilities.V, defaultCaps.V) {
+			t.Fatalf("%s (process capabilities): %#v", d.version, p.Capabilities.V)
+		}
+
+		docs, err := upgrade.Load(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, doc := range docs {
+			// the process states are left in the format of 17.06.1.
+			if doc.Kind != upgrade.KindProcess && doc.Version != Version {
+				t.Fatalf("%s: %s detected as %s", d.version, doc.Path, doc.Version)
+			}
+		}
+		if needed, err := upgrade.NeedsUpgrade(c, Version); err != nil || needed {
+			t.Fatalf("%s: needs upgrade: %v, %v", d.version, needed, err)
+		}
+	}
+}
+
+func TestDowngrade(t *testing.T) {
+	c, fs := fixtureContainer(t, "17.06.1")
+	original := make(map[string][]byte)
+	for _, path := range c.Files() {
+		b, err := readFile(fs, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		original[path] = b
+	}
+	if _, err := upgrade.Upgrade(c, upgrade.Options{Target: Version, IgnoreLiveness: true}); err != nil {
+		t.Fatal(err)
+	}
+	report, err := upgrade.Upgrade(c, upgrade.Options{Target: v17_06_1.Version, IgnoreLiveness: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 2 {
+		t.Fatalf("report: %+v", report.Files)
+	}
+	for path, expected := range original {
+		b, err := readFile(fs, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff, err := upgrade.DiffJSON(expected, b); err != nil || len(diff) != 0 {
+			t.Fatalf("%s: %v, %v", path, diff, err)
+		}
+	}
+}
+
+// TestUpgradeMultiHop checks that upgrading the files of 17.03 to 17.09.0 goes
+// through 17.06.1, and gives the same files as upgrading them to 17.06.1 then
+// to 17.09.0.
+func TestUpgradeMultiHop(t *testing.T) {
+	steps, err := upgrade.Plan(upgrade.V17_03, Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var path []upgrade.Version
+	for _, u := range steps {
+		path = append(path, u.To())
+	}
+	if len(path) < 2 || path[len(path)-2] != v17_06_1.Version || path[len(path)-1] != Version {
+		t.Fatalf("plan: %v", path)
+	}
+
+	direct, directFS := fixtureContainer(t, "17.03")
+	original := make(map[string][]byte)
+	for _, path := range direct.Files() {
+		b, err := readFile(directFS, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		original[path] = b
+	}
+	report, err := upgrade.Upgrade(direct, upgrade.Options{Target: Version, IgnoreLiveness: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 3 {
+		t.Fatalf("report: %+v", report.Files)
+	}
+	for _, f := range report.Files {
+		if f.To != Version || f.From == Version || f.From == v17_06_1.Version {
+			t.Fatalf("%s: %s -> %s", f.Path, f.From, f.To)
+		}
+	}
+
+	hops, hopsFS := fixtureContainer(t, "17.03")
+	for _, target := range []upgrade.Version{v17_06_1.Version, Version} {
+		if _, err := upgrade.Upgrade(hops, upgrade.Options{Target: target, IgnoreLiveness: true}); err != nil {
+			t.Fatalf("%s: %v", target, err)
+		}
+	}
+	for _, path := range direct.Files() {
+		a, err := readFile(directFS, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := readFile(hopsFS, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff, err := upgrade.DiffJSON(b, a); err != nil || len(diff) != 0 {
+			t.Fatalf("%s: %v, %v", path, diff, err)
+		}
+	}
+
+	// a single backup holds the files of 17.03.
+	if _, err := upgrade.Rollback(direct, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range original {
+		b, err := readFile(directFS, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != string(expected) {
+			t.Fatalf("%s not restored", path)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package v17_09_0
+
+import (
+	"fmt"
+
+	"github.com/crosbymichael/upgrade"
+	"github.com/crosbymichael/upgrade/v17_06_1"
+)
+
+func init() {
+	upgrade.Register(downgrader{})
+}
+
+// downgrader converts the files written by Docker 17.09.0 back to the format
+// of 17.06.1, so that an engine rolled back to 17.06.1 can restore the
+// containers. The runtime-spec versions change to those written by 17.06.1,
+// and the OOM killer setting moves back to the cgroup resources.
+type downgrader struct{}
+
+func (d downgrader) From() upgrade.Version { return Version }
+
+func (d downgrader) To() upgrade.Version { return v17_06_1.Version }
+
+func (d downgrader) Upgrade(doc *upgrade.Document) error {
+	switch doc.Kind {
+	case upgrade.KindState:
+		return convertJSON(doc, func(v map[string]interface{}) []upgrade.Loss {
+			if config, ok := v["config"].(map[string]interface{}); ok {
+				config["version"] = "1.0.0-rc5"
+			}
+			return nil
+		})
+	case upgrade.KindConfig:
+		return convertJSON(doc, downgradeSpec)
+	case upgrade.KindProcess:
+		return nil
+	}
+	return fmt.Errorf("unsupported document kind: %v", doc.Kind)
+}
+
+// downgradeSpec converts the config v to the runtime-spec of 17.06.1, which
+// still writes the OOM score adjustment in the process.
+func downgradeSpec(v map[string]interface{}) []upgrade.Loss {
+	v["ociVersion"] = "1.0.0-rc5-dev"
+	linux, _ := v["linux"].(map[string]interface{})
+	resources, _ := linux["resources"].(map[string]interface{})
+	memory, _ := resources["memory"].(map[string]interface{})
+	if disable, ok := memory["disableOOMKiller"]; ok {
+		resources["disableOOMKiller"] = disable
+		delete(memory, "disableOOMKiller")
+	}
+	return nil
+}
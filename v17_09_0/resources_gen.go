@@ -0,0 +1,39 @@
+// DO NOT EDIT
+// This file has been auto-generated with go generate.
+
+package v17_09_0
+
+import "github.com/opencontainers/runc/libcontainer/configs" // 3f2f8b84a77f73d38244dd690525642a72156c64
+
+type Resources struct {
+	AllowAllDevices              *bool                     `json:"allow_all_devices,omitempty"`
+	AllowedDevices               []*configs.Device         `json:"allowed_devices,omitempty"`
+	DeniedDevices                []*configs.Device         `json:"denied_devices,omitempty"`
+	Devices                      []*configs.Device         `json:"devices"`
+	Memory                       int64                     `json:"memory"`
+	MemoryReservation            int64                     `json:"memory_reservation"`
+	MemorySwap                   int64                     `json:"memory_swap"`
+	KernelMemory                 int64                     `json:"kernel_memory"`
+	KernelMemoryTCP              int64                     `json:"kernel_memory_tcp"`
+	CpuShares                    uint64                    `json:"cpu_shares"`
+	CpuQuota                     int64                     `json:"cpu_quota"`
+	CpuPeriod                    uint64                    `json:"cpu_period"`
+	CpuRtRuntime                 int64                     `json:"cpu_rt_quota"`
+	CpuRtPeriod                  uint64                    `json:"cpu_rt_period"`
+	CpusetCpus                   string                    `json:"cpuset_cpus"`
+	CpusetMems                   string                    `json:"cpuset_mems"`
+	PidsLimit                    int64                     `json:"pids_limit"`
+	BlkioWeight                  uint16                    `json:"blkio_weight"`
+	BlkioLeafWeight              uint16                    `json:"blkio_leaf_weight"`
+	BlkioWeightDevice            []*configs.WeightDevice   `json:"blkio_weight_device"`
+	BlkioThrottleReadBpsDevice   []*configs.ThrottleDevice `json:"blkio_throttle_read_bps_device"`
+	BlkioThrottleWriteBpsDevice  []*configs.ThrottleDevice `json:"blkio_throttle_write_bps_device"`
+	BlkioThrottleReadIOPSDevice  []*configs.ThrottleDevice `json:"blkio_throttle_read_iops_device"`
+	BlkioThrottleWriteIOPSDevice []*configs.ThrottleDevice `json:"blkio_throttle_write_iops_device"`
+	Freezer                      configs.FreezerState      `json:"freezer"`
+	HugetlbLimit                 []*configs.HugepageLimit  `json:"hugetlb_limit"`
+	OomKillDisable               bool                      `json:"oom_kill_disable"`
+	MemorySwappiness             cgroupSwappiness          `json:"memory_swappiness"`
+	NetPrioIfpriomap             []*configs.IfPrioMap      `json:"net_prio_ifpriomap"`
+	NetClsClassid                uint32                    `json:"net_cls_classid_u"`
+}
@@ -0,0 +1,66 @@
+// DO NOT EDIT
+// This file has been auto-generated with go generate.
+
+package v17_09_0
+
+import specs "github.com/opencontainers/runtime-spec/specs-go" // v1.0.0
+
+type Spec struct {
+	Version string `json:"ociVersion"`
+	Process *struct {
+		Terminal        bool                `json:"terminal,omitempty"`
+		ConsoleSize     *specs.Box          `json:"consoleSize,omitempty"`
+		User            specs.User          `json:"user"`
+		Args            []string            `json:"args"`
+		Env             []string            `json:"env,omitempty"`
+		Cwd             string              `json:"cwd"`
+		Capabilities    linuxCapabilities   `json:"capabilities,omitempty" platform:"linux"`
+		Rlimits         []specs.POSIXRlimit `json:"rlimits,omitempty" platform:"linux,solaris"`
+		NoNewPrivileges bool                `json:"noNewPrivileges,omitempty" platform:"linux"`
+		ApparmorProfile string              `json:"apparmorProfile,omitempty" platform:"linux"`
+		OOMScoreAdj     *int                `json:"oomScoreAdj,omitempty" platform:"linux"`
+		SelinuxLabel    string              `json:"selinuxLabel,omitempty" platform:"linux"`
+	} `json:"process,omitempty"`
+	Root        *specs.Root       `json:"root,omitempty"`
+	Hostname    string            `json:"hostname,omitempty"`
+	Mounts      []specs.Mount     `json:"mounts,omitempty"`
+	Hooks       *specs.Hooks      `json:"hooks,omitempty" platform:"linux,solaris"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Linux       *struct {
+		UIDMappings []specs.LinuxIDMapping `json:"uidMappings,omitempty"`
+		GIDMappings []specs.LinuxIDMapping `json:"gidMappings,omitempty"`
+		Sysctl      map[string]string      `json:"sysctl,omitempty"`
+		Resources   *struct {
+			Devices []specs.LinuxDeviceCgroup `json:"devices,omitempty"`
+			Memory  *struct {
+				Limit            *int64            `json:"limit,omitempty"`
+				Reservation      *int64            `json:"reservation,omitempty"`
+				Swap             *int64            `json:"swap,omitempty"`
+				Kernel           *int64            `json:"kernel,omitempty"`
+				KernelTCP        *int64            `json:"kernelTCP,omitempty"`
+				Swappiness       *memorySwappiness `json:"swappiness,omitempty"`
+				DisableOOMKiller *bool             `json:"disableOOMKiller,omitempty"`
+			} `json:"memory,omitempty"`
+			CPU            *specs.LinuxCPU            `json:"cpu,omitempty"`
+			Pids           *specs.LinuxPids           `json:"pids,omitempty"`
+			BlockIO        *linuxBlockIO              `json:"blockIO,omitempty"`
+			HugepageLimits []specs.LinuxHugepageLimit `json:"hugepageLimits,omitempty"`
+			Network        *specs.LinuxNetwork        `json:"network,omitempty"`
+		} `json:"resources,omitempty"`
+		CgroupsPath string                 `json:"cgroupsPath,omitempty"`
+		Namespaces  []specs.LinuxNamespace `json:"namespaces,omitempty"`
+		Devices     []specs.LinuxDevice    `json:"devices,omitempty"`
+		Seccomp     *struct {
+			DefaultAction specs.LinuxSeccompAction `json:"defaultAction"`
+			Architectures []specs.Arch             `json:"architectures,omitempty"`
+			Syscalls      linuxSyscalls            `json:"syscalls,omitempty"`
+		} `json:"seccomp,omitempty"`
+		RootfsPropagation string               `json:"rootfsPropagation,omitempty"`
+		MaskedPaths       []string             `json:"maskedPaths,omitempty"`
+		ReadonlyPaths     []string             `json:"readonlyPaths,omitempty"`
+		MountLabel        string               `json:"mountLabel,omitempty"`
+		IntelRdt          *specs.LinuxIntelRdt `json:"intelRdt,omitempty"`
+	} `json:"linux,omitempty" platform:"linux"`
+	Solaris *specs.Solaris `json:"solaris,omitempty" platform:"solaris"`
+	Windows *specs.Windows `json:"windows,omitempty" platform:"windows"`
+}
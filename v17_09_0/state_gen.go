@@ -0,0 +1,60 @@
+// DO NOT EDIT
+// This file has been auto-generated with go generate.
+
+package v17_09_0
+
+import (
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/configs" // 3f2f8b84a77f73d38244dd690525642a72156c64
+)
+
+type State struct {
+	ID                   string                   `json:"id"`
+	InitProcessPid       int                      `json:"init_process_pid"`
+	InitProcessStartTime initProcessStartTimeType `json:"init_process_start"`
+	Created              time.Time                `json:"created"`
+	Config               struct {
+		NoPivotRoot       bool                  `json:"no_pivot_root"`
+		ParentDeathSignal int                   `json:"parent_death_signal"`
+		Rootfs            string                `json:"rootfs"`
+		Readonlyfs        bool                  `json:"readonlyfs"`
+		RootPropagation   int                   `json:"rootPropagation"`
+		Mounts            []*configs.Mount      `json:"mounts"`
+		Devices           []*configs.Device     `json:"devices"`
+		MountLabel        string                `json:"mount_label"`
+		Hostname          string                `json:"hostname"`
+		Namespaces        configs.Namespaces    `json:"namespaces"`
+		Capabilities      *configs.Capabilities `json:"capabilities"`
+		Networks          []*configs.Network    `json:"networks"`
+		Routes            []*configs.Route      `json:"routes"`
+		Cgroups           *struct {
+			Name        string `json:"name,omitempty"`
+			Parent      string `json:"parent,omitempty"`
+			Path        string `json:"path"`
+			ScopePrefix string `json:"scope_prefix"`
+			Paths       map[string]string
+			*Resources
+		} `json:"cgroups"`
+		AppArmorProfile string            `json:"apparmor_profile,omitempty"`
+		ProcessLabel    string            `json:"process_label,omitempty"`
+		Rlimits         []configs.Rlimit  `json:"rlimits,omitempty"`
+		OomScoreAdj     int               `json:"oom_score_adj"`
+		UidMappings     []configs.IDMap   `json:"uid_mappings"`
+		GidMappings     []configs.IDMap   `json:"gid_mappings"`
+		MaskPaths       []string          `json:"mask_paths"`
+		ReadonlyPaths   []string          `json:"readonly_paths"`
+		Sysctl          map[string]string `json:"sysctl"`
+		Seccomp         *configs.Seccomp  `json:"seccomp"`
+		NoNewPrivileges bool              `json:"no_new_privileges,omitempty"`
+		Hooks           *configs.Hooks
+		Version         string   `json:"version"`
+		Labels          []string `json:"labels"`
+		NoNewKeyring    bool     `json:"no_new_keyring"`
+		Rootless        bool     `json:"rootless"`
+	} `json:"config"`
+	Rootless            bool                             `json:"rootless"`
+	CgroupPaths         map[string]string                `json:"cgroup_paths"`
+	NamespacePaths      map[configs.NamespaceType]string `json:"namespace_paths"`
+	ExternalDescriptors []string                         `json:"external_descriptors,omitempty"`
+}
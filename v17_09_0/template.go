@@ -0,0 +1,24 @@
+//+build ignore
+
+package v17_09_0
+
+import (
+	"github.com/containerd/containerd/runtime"
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+//go:generate -command rewrite go run ../gen/rewrite-structs.go --
+
+//go:generate rewrite spec_gen.go .Process.Capabilities->linuxCapabilities .Linux.Resources.Memory.Swappiness->*memorySwappiness .Linux.Seccomp.Syscalls->linuxSyscalls .Linux.Resources.BlockIO->*linuxBlockIO
+type Spec specs.Spec
+
+//go:generate rewrite process_state_gen.go .Capabilities->linuxCapabilities
+type ProcessState runtime.ProcessState
+
+//go:generate rewrite state_gen.go .InitProcessStartTime->initProcessStartTimeType .Config.Cgroups.Resources->*Resources
+type State libcontainer.State
+
+//go:generate rewrite resources_gen.go .MemorySwappiness->cgroupSwappiness
+type Resources configs.Resources
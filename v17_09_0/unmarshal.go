@@ -0,0 +1,116 @@
+package v17_09_0
+
+import (
+	"encoding/json"
+
+	"github.com/crosbymichael/upgrade"
+	"github.com/crosbymichael/upgrade/v17_06_1"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// The types below decode the fields whose format changed before 17.09.0 with
+// the shims of v17_06_1, so that the types of this package accept the files of
+// every supported version. Only the types of the runtime-spec, vendored
+// separately, are declared again.
+
+type initProcessStartTimeType = v17_06_1.InitProcessStartTime
+
+// memorySwappiness is a v17_06_1.MemorySwappiness.
+type memorySwappiness v17_06_1.MemorySwappiness
+
+func (m memorySwappiness) String() string {
+	return v17_06_1.MemorySwappiness(m).String()
+}
+
+func (m memorySwappiness) compare(o memorySwappiness) int {
+	return v17_06_1.MemorySwappiness(m).Compare(v17_06_1.MemorySwappiness(o))
+}
+
+func (m memorySwappiness) MarshalJSON() ([]byte, error) {
+	return v17_06_1.MemorySwappiness(m).MarshalJSON()
+}
+
+func (m *memorySwappiness) UnmarshalJSON(b []byte) error {
+	return (*v17_06_1.MemorySwappiness)(m).UnmarshalJSON(b)
+}
+
+type cgroupSwappiness = v17_06_1.CgroupSwappiness
+
+type linuxSyscalls []linuxSyscall
+
+type linuxSyscall struct {
+	specs.LinuxSyscall
+}
+
+type linuxSyscallJSON struct {
+	specs.LinuxSyscall
+	v17_06_1.LegacySyscall
+}
+
+func (ls *linuxSyscall) Decoded() interface{} {
+	return &linuxSyscallJSON{}
+}
+
+func (ls *linuxSyscall) UnmarshalJSON(b []byte) error {
+	var t linuxSyscallJSON
+	if err := json.Unmarshal(b, &t); err != nil {
+		return err
+	}
+	names, err := t.LegacySyscall.Merge(t.LinuxSyscall.Names)
+	if err != nil {
+		return err
+	}
+	ls.LinuxSyscall = t.LinuxSyscall
+	ls.LinuxSyscall.Names = names
+	return nil
+}
+
+type linuxCapabilities struct {
+	V *specs.LinuxCapabilities
+}
+
+func (l *linuxCapabilities) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.V)
+}
+
+// Decoded returns the type of the capability sets. The flat lists written
+// before Docker 17.06 have no fields.
+func (l *linuxCapabilities) Decoded() interface{} {
+	return &specs.LinuxCapabilities{}
+}
+
+func (l *linuxCapabilities) UnmarshalJSON(b []byte) error {
+	sets, err := v17_06_1.DecodeCapabilities(b, upgrade.DockerCapabilities, nil)
+	if err != nil || sets == nil {
+		return err
+	}
+	l.V = &specs.LinuxCapabilities{
+		Bounding:    sets.Bounding,
+		Effective:   sets.Effective,
+		Inheritable: sets.Inheritable,
+		Permitted:   sets.Permitted,
+		Ambient:     sets.Ambient,
+	}
+	return nil
+}
+
+type linuxBlockIO struct {
+	specs.LinuxBlockIO
+}
+
+type linuxBlockIOJSON struct {
+	specs.LinuxBlockIO
+	v17_06_1.LegacyBlockIO
+}
+
+func (l *linuxBlockIO) Decoded() interface{} {
+	return &linuxBlockIOJSON{}
+}
+
+func (l *linuxBlockIO) UnmarshalJSON(b []byte) error {
+	b, err := v17_06_1.UpgradeBlockIO(b)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &l.LinuxBlockIO)
+}
@@ -0,0 +1,151 @@
+package v17_09_0
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/crosbymichael/upgrade"
+	"github.com/crosbymichael/upgrade/v17_06_1"
+)
+
+// Version is the version of the state files produced by this package.
+const Version = upgrade.V17_09_0
+
+// specVersion is the runtime-spec version that Docker 17.09.0 writes in the
+// config, and that its runc records in the state.
+const specVersion = "1.0.0"
+
+func init() {
+	// the earlier releases are upgraded to 17.06.1 first.
+	upgrade.Register(upgrader{})
+	// the checks of 17.06.1 still apply: they do not involve the fields
+	// moved by runtime-spec v1.0.0.
+	upgrade.RegisterValidator(v17_06_1.NewValidator(Version))
+}
+
+type upgrader struct{}
+
+func (u upgrader) From() upgrade.Version { return v17_06_1.Version }
+
+func (u upgrader) To() upgrade.Version { return Version }
+
+// Upgrade sets the runtime-spec version of the runc state, and converts the
+// config to runtime-spec v1.0.0. The process states are left as they are.
+func (u upgrader) Upgrade(doc *upgrade.Document) error {
+	switch doc.Kind {
+	case upgrade.KindState:
+		return upgradeState(doc)
+	case upgrade.KindConfig:
+		return convertJSON(doc, upgradeSpec)
+	case upgrade.KindProcess:
+		return nil
+	}
+	return fmt.Errorf("unsupported document kind: %v", doc.Kind)
+}
+
+func upgradeState(doc *upgrade.Document) error {
+	var s State
+	if err := json.Unmarshal(doc.Data, &s); err != nil {
+		return &upgrade.DecodeError{Err: err}
+	}
+	unknown, err := upgrade.UnknownFields(doc.Data, &s)
+	if err != nil {
+		return err
+	}
+	s.Config.Version = specVersion
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(&s); err != nil {
+		return err
+	}
+	doc.Data = buf.Bytes()
+	doc.Unknown = unknown
+	return nil
+}
+
+// upgradeSpec converts the config v to runtime-spec v1.0.0, which dropped the
+// platform and moved the OOM settings out of the cgroup resources: the score
+// adjustment to the process, and the OOM killer to the memory resources.
+func upgradeSpec(v map[string]interface{}) []upgrade.Loss {
+	var losses []upgrade.Loss
+	v["ociVersion"] = specVersion
+	if platform, ok := v["platform"].(map[string]interface{}); ok {
+		losses = append(losses, upgrade.Loss{Field: upgrade.Field{"platform"}, Reason: fmt.Sprintf("not recorded since runtime-spec v1.0.0, was %v/%v", platform["os"], platform["arch"])})
+	}
+	delete(v, "platform")
+	linux, _ := v["linux"].(map[string]interface{})
+	resources, _ := linux["resources"].(map[string]interface{})
+	if resources == nil {
+		return losses
+	}
+	if oomScoreAdj, ok := resources["oomScoreAdj"]; ok {
+		// 17.06.1 already writes it in the process.
+		process, _ := v["process"].(map[string]interface{})
+		switch current, ok := process["oomScoreAdj"]; {
+		case process == nil:
+			losses = append(losses, upgrade.Loss{Field: upgrade.Field{"linux", "resources", "oomScoreAdj"}, Reason: "no process section to move it to"})
+		case !ok:
+			process["oomScoreAdj"] = oomScoreAdj
+		case current != oomScoreAdj:
+			losses = append(losses, upgrade.Loss{Field: upgrade.Field{"linux", "resources", "oomScoreAdj"}, Reason: fmt.Sprintf("superseded by .process.oomScoreAdj %v", current)})
+		}
+		delete(resources, "oomScoreAdj")
+	}
+	if disable, ok := resources["disableOOMKiller"]; ok {
+		memory, _ := resources["memory"].(map[string]interface{})
+		if memory == nil {
+			memory = make(map[string]interface{})
+			resources["memory"] = memory
+		}
+		if _, ok := memory["disableOOMKiller"]; !ok {
+			memory["disableOOMKiller"] = disable
+		}
+		delete(resources, "disableOOMKiller")
+	}
+	return losses
+}
+
+// convertJSON applies convert to doc, decoded as a JSON object, so that the
+// fields convert does not know about are kept.
+func convertJSON(doc *upgrade.Document, convert func(v map[string]interface{}) []upgrade.Loss) error {
+	var v map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(doc.Data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return &upgrade.DecodeError{Err: err}
+	}
+	losses := convert(v)
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	doc.Data = buf.Bytes()
+	doc.Losses = losses
+	return nil
+}
+
+// container returns the container of the given files. Its ID is the name of
+// the directory of the runc state, as laid out by Docker.
+func container(runcState, containerdConfig, containerdProcess string) upgrade.Container {
+	return upgrade.Container{
+		ID:      filepath.Base(filepath.Dir(runcState)),
+		State:   runcState,
+		Config:  containerdConfig,
+		Process: containerdProcess,
+	}
+}
+
+// NeedsUpgrade reports whether any of the given files was written by an
+// earlier version.
+func NeedsUpgrade(runcState, containerdConfig, containerdProcess string) (bool, error) {
+	return upgrade.NeedsUpgrade(container(runcState, containerdConfig, containerdProcess), Version)
+}
+
+// Upgrade converts the given files to the format of Docker 17.09.0. It does
+// not check whether the init process recorded in runcState is still running;
+// see upgrade.Upgrade for that.
+func Upgrade(runcState, containerdConfig, containerdProcess string) error {
+	_, err := upgrade.Upgrade(container(runcState, containerdConfig, containerdProcess), upgrade.Options{Target: Version, IgnoreLiveness: true})
+	return err
+}
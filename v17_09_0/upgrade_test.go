@@ -0,0 +1,222 @@
+package v17_09_0
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/crosbymichael/upgrade"
+	"github.com/docker/go/canonical/json"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// The fixtures of testfiles/ are the files of ../testfiles re-encoded as
+// canonical JSON. Decoding them into the types of this package and encoding
+// them again changes the fields that Docker 17.09.0 writes differently; the
+// tests list these changes as in upgrade.Diff, without the array indexes.
+
+var defaultCapsList = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_FSETID",
+	"CAP_FOWNER",
+	"CAP_MKNOD",
+	"CAP_NET_RAW",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETFCAP",
+	"CAP_SETPCAP",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_SYS_CHROOT",
+	"CAP_KILL",
+	"CAP_AUDIT_WRITE",
+}
+
+var defaultCaps = linuxCapabilities{&specs.LinuxCapabilities{
+	Bounding:    defaultCapsList,
+	Effective:   defaultCapsList,
+	Inheritable: defaultCapsList,
+	Permitted:   defaultCapsList,
+}}
+
+func decode(t *testing.T, filename string, x interface{}) (content []byte) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("readfile %s: (%T) %v", filename, err, err)
+	}
+	if err := json.Unmarshal(content, x); err != nil {
+		t.Fatalf("decode %s to %T: (%T) %v", filename, x, err, err)
+	}
+	return content
+}
+
+// legacyConfigChanges are the changes to the configs written before Docker
+// 17.06: runtime-spec v1.0.0 renamed the block IO settings, moved the OOM
+// settings and dropped the platform, and the flat capability lists and the
+// seccomp rules naming a single syscall are decoded in the format of 17.06.
+var legacyConfigChanges = []string{
+	"- .linux.resources.blockIO.blkioWeight",
+	"+ .linux.resources.blockIO.weight",
+	"- .linux.resources.disableOOMKiller",
+	"- .linux.resources.oomScoreAdj",
+	"- .linux.seccomp.syscalls[].name",
+	"+ .linux.seccomp.syscalls[].names",
+	"- .platform",
+	"~ .process.capabilities",
+}
+
+func TestConfig(t *testing.T) {
+	for _, d := range [...]struct {
+		filename         string
+		caps             linuxCapabilities
+		memorySwappiness memorySwappiness
+		changes          []string
+	}{
+		{"testfiles/config-sorted-17.03.json", defaultCaps, memorySwappiness{nil}, legacyConfigChanges},
+		{"testfiles/config-unwrapped-17.05.json", defaultCaps, memorySwappiness{nil}, legacyConfigChanges},
+		{"testfiles/config-unwrapped-17.06.0.json", defaultCaps, memorySwappiness{nil}, []string{
+			"- .linux.resources.blockIO.blkioWeight",
+			"+ .linux.resources.blockIO.weight",
+			"- .linux.resources.disableOOMKiller",
+			"- .linux.resources.oomScoreAdj",
+			// empty lists of arguments are omitted.
+			"- .linux.seccomp.syscalls[].args",
+			"- .linux.seccomp.syscalls[].comment",
+			"- .platform",
+		}},
+		{"testfiles/config-unwrapped-17.06.1.json", defaultCaps, memorySwappiness{nil}, []string{
+			"- .linux.resources.disableOOMKiller",
+		}},
+		// TODO: add tests for non-nil memorySwappiness
+	} {
+		var spec Spec
+		content := decode(t, d.filename, &spec)
+
+		if !reflect.DeepEqual(spec.Process.Capabilities.V, d.caps.V) {
+			t.Fatalf("validate %s (capabilities): %#v | %#v", d.filename, d.caps.V, spec.Process.Capabilities.V)
+		}
+		if d.memorySwappiness.compare(*spec.Linux.Resources.Memory.Swappiness) != 0 {
+			t.Fatalf("validate %s (memorySwappiness): %s | %s", d.filename, d.memorySwappiness, spec.Linux.Resources.Memory.Swappiness)
+		}
+
+		marshalCompare(t, d.filename, content, &spec, d.changes)
+	}
+
+}
+
+func TestProcess(t *testing.T) {
+	for _, d := range [...]struct {
+		filename string
+		caps     linuxCapabilities
+		changes  []string
+	}{
+		{"testfiles/process-unwrapped-17.03.json", defaultCaps, []string{"~ .capabilities"}},
+		{"testfiles/process-unwrapped-17.05.json", defaultCaps, []string{"~ .capabilities"}},
+		{"testfiles/process-unwrapped-17.06.0.json", defaultCaps, nil},
+		{"testfiles/process-unwrapped-17.06.1.json", defaultCaps, nil},
+	} {
+		var s ProcessState
+		content := decode(t, d.filename, &s)
+
+		if !reflect.DeepEqual(s.Capabilities.V, d.caps.V) {
+			t.Fatalf("validate %s (capabilities): %#v | %#v", d.filename, d.caps.V, s.Capabilities.V)
+		}
+
+		marshalCompare(t, d.filename, content, &s, d.changes)
+	}
+}
+
+var legacyStateChanges = []string{
+	"~ .config.cgroups.memory_swappiness",
+	"+ .config.rootless",
+	"~ .init_process_start",
+	"+ .rootless",
+}
+
+func TestState(t *testing.T) {
+	for _, d := range [...]struct {
+		filename             string
+		initProcessStartTime initProcessStartTimeType
+		memorySwappiness     memorySwappiness
+		changes              []string
+	}{
+		// runc writes the start time of the init process as a number,
+		// null for the default swappiness, and whether the container is
+		// rootless since 17.06.
+		{"testfiles/state-unwrapped-17.03.json", 8468990, memorySwappiness{nil}, legacyStateChanges},
+		{"testfiles/state-unwrapped-17.05.json", 8504596, memorySwappiness{nil}, legacyStateChanges},
+		{"testfiles/state-unwrapped-17.06.0.json", 8497004, memorySwappiness{nil}, []string{
+			"~ .config.cgroups.memory_swappiness",
+			"~ .init_process_start",
+		}},
+		{"testfiles/state-unwrapped-17.06.1.json", 8497004, memorySwappiness{nil}, nil},
+		// TODO: add tests for non-nil memorySwappiness
+	} {
+		var s State
+		content := decode(t, d.filename, &s)
+
+		if s.InitProcessStartTime != d.initProcessStartTime {
+			t.Fatalf("validate %s (initProcessStartTime): %d | %d", d.filename, d.initProcessStartTime, s.InitProcessStartTime)
+		}
+
+		if d.memorySwappiness.compare(memorySwappiness(s.Config.Cgroups.Resources.MemorySwappiness)) != 0 {
+			t.Fatalf("validate %s (memorySwappiness): %s | %s", d.filename, d.memorySwappiness, s.Config.Cgroups.Resources.MemorySwappiness)
+		}
+
+		marshalCompare(t, d.filename, content, &s, d.changes)
+	}
+}
+
+const debug = false
+
+var (
+	changeOps  = map[upgrade.Op]string{upgrade.OpAdd: "+", upgrade.OpRemove: "-", upgrade.OpReplace: "~"}
+	arrayIndex = regexp.MustCompile(`\[[0-9]+\]`)
+)
+
+// marshalCompare checks that x, decoded from content, encodes to content but
+// for the given changes.
+func marshalCompare(t *testing.T, filename string, content []byte, x interface{}, expected []string) {
+	marshalled, err := json.MarshalCanonical(x)
+	if err != nil {
+		t.Fatalf("marshal %s (%T): (%T) %v", filename, x, err, err)
+	}
+	diff, err := upgrade.DiffJSON(content, marshalled)
+	if err != nil {
+		t.Fatalf("validate %s (diff): %v", filename, err)
+	}
+	var changes []string
+	seen := make(map[string]bool)
+	for _, c := range diff {
+		change := changeOps[c.Op] + " " + arrayIndex.ReplaceAllString(c.Path, "[]")
+		if !seen[change] {
+			seen[change] = true
+			changes = append(changes, change)
+		}
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		if debug {
+			// for display purposes (easier diff), indent the json.
+			marshalled, err := indentBytes(marshalled, "", "\t")
+			if err != nil {
+				t.Fatalf("validate %s (marshal+indent1): %v", filename, err)
+			}
+			content, err := indentBytes(content, "", "\t")
+			if err != nil {
+				t.Fatalf("validate %s (marshal+indent2): %v", filename, err)
+			}
+			t.Logf("%s\n==================================\n%s\n", content, marshalled)
+		}
+		t.Errorf("validate %s (marshal): changes %q, expected %q", filename, changes, expected)
+	}
+}
+
+func indentBytes(b []byte, prefix, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,124 @@
+package upgrade
+
+import "fmt"
+
+// A Validator checks that the state files of a container agree with each
+// other: they are written by runc and containerd from the same request, and
+// a disagreement reveals a half-migrated or corrupted container that would
+// fail to be restored. Validators are provided by the versioned packages,
+// which register them in their init function along with their upgraders.
+type Validator interface {
+	// Version is the version of the documents accepted by Validate.
+	Version() Version
+	// Validate returns every disagreement between the documents of c. The
+	// documents include the states of the exec processes of c, which are
+	// not derived from its config. It fails with a *DecodeError if a
+	// document cannot be decoded.
+	Validate(c Container, docs []*Document) ([]Mismatch, error)
+}
+
+// A DocumentValidator is a Validator that also checks each document on its
+// own, against the specification of its format.
+type DocumentValidator interface {
+	Validator
+	// ValidateDocument returns the violations of the specification by
+	// doc, at Version. It fails with a *DecodeError if doc cannot be
+	// decoded.
+	ValidateDocument(doc *Document) ([]Violation, error)
+}
+
+// A Mismatch is a disagreement between state files of a container, or
+// between a state file and the location of the container.
+type Mismatch struct {
+	// Kinds are the kinds of the documents that disagree. KindProcess
+	// stands for the state of the init process.
+	Kinds  []Kind
+	Reason string
+}
+
+// RegisterValidator makes v check the documents at v.Version(). It panics if
+// a validator for the same version is already registered.
+func RegisterValidator(v Validator) {
+	defaultRegistry.registerValidator(v)
+}
+
+func (r *registry) registerValidator(v Validator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v == nil {
+		panic("upgrade: RegisterValidator validator is nil")
+	}
+	if _, dup := r.validators[v.Version()]; dup {
+		panic(fmt.Sprintf("upgrade: RegisterValidator called twice for %s", v.Version()))
+	}
+	if r.validators == nil {
+		r.validators = make(map[Version]Validator)
+	}
+	r.validators[v.Version()] = v
+}
+
+func (r *registry) validator(v Version) Validator {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.validators[v]
+}
+
+// ValidateDocument returns the violations by doc, in the format of version, of
+// the specification of that format. Nothing is checked if no
+// DocumentValidator is registered for version.
+func ValidateDocument(doc *Document, version Version) ([]Violation, error) {
+	v, ok := defaultRegistry.validator(version).(DocumentValidator)
+	if !ok {
+		return nil, nil
+	}
+	return v.ValidateDocument(doc)
+}
+
+// invalid returns Errors holding an *InvalidError per file with violations,
+// or nil if there is none or opts.AllowInvalid is set.
+func invalid(files []FileReport, opts Options) error {
+	if opts.AllowInvalid {
+		return nil
+	}
+	var errs Errors
+	for _, f := range files {
+		if len(f.Violations) > 0 {
+			errs = append(errs, &InvalidError{File: f.Path, Kind: f.Kind, Violations: f.Violations})
+		}
+	}
+	if errs == nil {
+		return nil
+	}
+	return errs
+}
+
+// Validate checks that the documents of c, in the format of version, agree
+// with each other. It returns Errors holding a *ConsistencyError per
+// mismatch, or nil if there is none. Nothing is checked if no validator is
+// registered for version.
+func Validate(c Container, docs []*Document, version Version) error {
+	v := defaultRegistry.validator(version)
+	if v == nil {
+		return nil
+	}
+	mismatches, err := v.Validate(c, docs)
+	if err != nil {
+		return err
+	}
+	var errs Errors
+	for _, m := range mismatches {
+		e := &ConsistencyError{Container: c.ID, Reason: m.Reason}
+		for _, kind := range m.Kinds {
+			for _, doc := range docs {
+				if doc.Kind == kind && !c.isExec(doc.Path) {
+					e.Files = append(e.Files, doc.Path)
+				}
+			}
+		}
+		errs = append(errs, e)
+	}
+	if errs == nil {
+		return nil
+	}
+	return errs
+}
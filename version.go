@@ -0,0 +1,90 @@
+package upgrade
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is the Docker release that wrote a state file.
+type Version string
+
+// Known versions.
+const (
+	Unknown  Version = ""
+	V17_03   Version = "17.03"
+	V17_05   Version = "17.05"
+	V17_06_0 Version = "17.06.0"
+	V17_06_1 Version = "17.06.1"
+	V17_09_0 Version = "17.09.0"
+)
+
+// versions lists the known versions, oldest first.
+var versions = []Version{V17_03, V17_05, V17_06_0, V17_06_1, V17_09_0}
+
+// Versions returns the known versions, oldest first.
+func Versions() []Version {
+	return append([]Version(nil), versions...)
+}
+
+func (v Version) String() string {
+	if v == Unknown {
+		return "unknown"
+	}
+	return string(v)
+}
+
+// ParseVersion returns the known version named s.
+func ParseVersion(s string) (Version, error) {
+	for _, v := range versions {
+		if string(v) == s {
+			return v, nil
+		}
+	}
+	return Unknown, fmt.Errorf("unknown version: %s", s)
+}
+
+// compare orders versions by their dot-separated numeric components.
+func compare(a, b Version) int {
+	x, y := strings.Split(string(a), "."), strings.Split(string(b), ".")
+	for i := 0; i < len(x) || i < len(y); i++ {
+		var m, n int
+		if i < len(x) {
+			m, _ = strconv.Atoi(x[i])
+		}
+		if i < len(y) {
+			n, _ = strconv.Atoi(y[i])
+		}
+		switch {
+		case m < n:
+			return -1
+		case m > n:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Kind is the type of a state file.
+type Kind int
+
+const (
+	// KindState is the runc state.json.
+	KindState Kind = iota
+	// KindConfig is the libcontainerd config.json, an OCI runtime spec.
+	KindConfig
+	// KindProcess is a libcontainerd process state, such as init-process.json.
+	KindProcess
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindState:
+		return "state"
+	case KindConfig:
+		return "config"
+	case KindProcess:
+		return "process"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}